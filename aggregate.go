@@ -0,0 +1,215 @@
+package iondb
+
+import "unsafe"
+
+// Count returns how many records are left in cursor, consuming it.
+func Count[K, V any](cursor *Cursor[K, V]) int {
+	count := 0
+	for cursor.Next(); cursor.HasNext(); cursor.Next() {
+		count++
+	}
+	return count
+}
+
+// Sum returns the sum of project over the records left in cursor.
+func Sum[K, V any](cursor *Cursor[K, V], project func(V) float64) float64 {
+	sum := 0.0
+	for cursor.Next(); cursor.HasNext(); cursor.Next() {
+		sum += project(cursor.GetValue())
+	}
+	return sum
+}
+
+// Avg returns the mean of project over the records left in cursor. It is
+// false if there are none.
+func Avg[K, V any](cursor *Cursor[K, V], project func(V) float64) (float64, bool) {
+	sum := 0.0
+	count := 0
+	for cursor.Next(); cursor.HasNext(); cursor.Next() {
+		sum += project(cursor.GetValue())
+		count++
+	}
+	if count == 0 {
+		return 0, false
+	}
+	return sum / float64(count), true
+}
+
+// Min returns the smallest value of project over the records left in
+// cursor. It is false if there are none.
+func Min[K, V any](cursor *Cursor[K, V], project func(V) float64) (float64, bool) {
+	return foldValues(cursor, project, func(a, b float64) bool { return a < b })
+}
+
+// Max returns the largest value of project over the records left in cursor.
+// It is false if there are none.
+func Max[K, V any](cursor *Cursor[K, V], project func(V) float64) (float64, bool) {
+	return foldValues(cursor, project, func(a, b float64) bool { return a > b })
+}
+
+func foldValues[K, V any](cursor *Cursor[K, V], project func(V) float64, better func(a, b float64) bool) (float64, bool) {
+	found := false
+	best := 0.0
+	for cursor.Next(); cursor.HasNext(); cursor.Next() {
+		val := project(cursor.GetValue())
+		if !found || better(val, best) {
+			best = val
+			found = true
+		}
+	}
+	return best, found
+}
+
+// CountRange returns how many records have a key between minKey and maxKey,
+// found from the link widths in O(log n) without visiting the records.
+// Expired records still count until they are swept.
+func (sl *SkipList[K, V]) CountRange(minKey, maxKey K) int {
+	skipList := (*ionSkipList)(unsafe.Pointer(sl.dict.instance))
+	ionMinKey := IonKey(unsafe.Pointer(&minKey))
+	ionMaxKey := IonKey(unsafe.Pointer(&maxKey))
+	if skipList.super.compare(ionMinKey, ionMaxKey, skipList.super.record.keySize) > 0 {
+		return 0
+	}
+	return slCountBefore(skipList, ionMaxKey, true) - slCountBefore(skipList, ionMinKey, false)
+}
+
+// Rank returns how many records have a key smaller than key, which is the
+// position of the first record of key counting from 0 if it is present.
+// Expired records still count until they are swept.
+func (sl *SkipList[K, V]) Rank(key K) int {
+	skipList := (*ionSkipList)(unsafe.Pointer(sl.dict.instance))
+	return slCountBefore(skipList, IonKey(unsafe.Pointer(&key)), false)
+}
+
+// Select returns the record at position i in key order, counting from 0 and
+// duplicates in insertion order. It is false if i is out of range.
+func (sl *SkipList[K, V]) Select(i int) (K, V, bool) {
+	var key K
+	var val V
+	skipList := (*ionSkipList)(unsafe.Pointer(sl.dict.instance))
+	node := slSelect(skipList, i+1)
+	if node == nil {
+		return key, val, false
+	}
+	key = *((*K)(node.key))
+	slReadValue(skipList, node, IonValue(unsafe.Pointer(&val)))
+	return key, val, true
+}
+
+// MinKey returns the smallest key between minKey and maxKey, found from the
+// first node of the range. It is false if the range is empty.
+func (sl *SkipList[K, V]) MinKey(minKey, maxKey K) (K, bool) {
+	var key K
+	skipList := (*ionSkipList)(unsafe.Pointer(sl.dict.instance))
+	found := false
+	slWalkRange(skipList, IonKey(unsafe.Pointer(&minKey)), IonKey(unsafe.Pointer(&maxKey)), func(node *ionSlNode) bool {
+		key = *((*K)(node.key))
+		found = true
+		return false
+	})
+	return key, found
+}
+
+// MaxKey returns the largest key between minKey and maxKey, found from the
+// last node not after maxKey. It is false if the range is empty.
+func (sl *SkipList[K, V]) MaxKey(minKey, maxKey K) (K, bool) {
+	var key K
+	skipList := (*ionSkipList)(unsafe.Pointer(sl.dict.instance))
+	ionMinKey := IonKey(unsafe.Pointer(&minKey))
+	ionMaxKey := IonKey(unsafe.Pointer(&maxKey))
+	kSize := skipList.super.record.keySize
+
+	last := slLastNotAfter(skipList, ionMaxKey)
+	if last.key == nil || skipList.super.compare(last.key, ionMinKey, kSize) < 0 {
+		return key, false
+	}
+	for node := last; node != nil && skipList.super.compare(node.key, last.key, kSize) == 0; node = node.next[0] {
+		if !slExpired(skipList, node) {
+			return *((*K)(node.key)), true
+		}
+	}
+
+	// every record of the last key expired, fall back to walking the range.
+	found := false
+	slWalkRange(skipList, ionMinKey, ionMaxKey, func(node *ionSlNode) bool {
+		key = *((*K)(node.key))
+		found = true
+		return true
+	})
+	return key, found
+}
+
+// slWalkRange calls visit on every live node with a key between minKey and
+// maxKey until visit returns false.
+func slWalkRange(skipList *ionSkipList, minKey IonKey, maxKey IonKey, visit func(node *ionSlNode) bool) {
+	kSize := skipList.super.record.keySize
+	finger := slNewFinger(skipList)
+	slFingerSearch(skipList, finger, minKey)
+	for node := finger.node[0].next[0]; node != nil && skipList.super.compare(node.key, maxKey, kSize) <= 0; node = node.next[0] {
+		if slExpired(skipList, node) {
+			continue
+		}
+		if !visit(node) {
+			return
+		}
+	}
+}
+
+// slLastNotAfter returns the first node of the largest key not after key,
+// or the head if there is none.
+func slLastNotAfter(skipList *ionSkipList, key IonKey) *ionSlNode {
+	kSize := skipList.super.record.keySize
+	cursor := skipList.head
+	var h ionSlLevel
+	for h = skipList.head.height; h >= 0; h-- {
+		for cursor.next[h] != nil && skipList.super.compare(cursor.next[h].key, key, kSize) <= 0 {
+			cursor = cursor.next[h]
+		}
+	}
+	if cursor == skipList.head {
+		return cursor
+	}
+	// level 0 may have run into the duplicates, step back to the first one.
+	return slFindNode(skipList, cursor.key)
+}
+
+// slCountBefore returns how many nodes have a key smaller than key, or not
+// greater than key if inclusive is set.
+func slCountBefore(skipList *ionSkipList, key IonKey, inclusive bool) int {
+	kSize := skipList.super.record.keySize
+	cursor := skipList.head
+	pos := 0
+	var h ionSlLevel
+	for h = skipList.head.height; h >= 0; h-- {
+		for cursor.next[h] != nil {
+			cmp := skipList.super.compare(cursor.next[h].key, key, kSize)
+			if cmp > 0 || (cmp == 0 && !inclusive) {
+				break
+			}
+			pos += cursor.width[h]
+			cursor = cursor.next[h]
+		}
+	}
+	return pos
+}
+
+// slSelect returns the node at position pos, counting the first node as 1,
+// or nil if there is none.
+func slSelect(skipList *ionSkipList, pos int) *ionSlNode {
+	if pos < 1 {
+		return nil
+	}
+	cursor := skipList.head
+	walked := 0
+	var h ionSlLevel
+	for h = skipList.head.height; h >= 0; h-- {
+		for cursor.next[h] != nil && walked+cursor.width[h] <= pos {
+			walked += cursor.width[h]
+			cursor = cursor.next[h]
+		}
+	}
+	if walked != pos {
+		return nil
+	}
+	return cursor
+}
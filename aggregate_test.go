@@ -0,0 +1,131 @@
+package iondb
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestAggregates(t *testing.T) {
+	one := 1
+	dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(one)), 7)
+	for _, key := range []int{2, 4, 6, 8, 10} {
+		dict.Insert(key, key*10)
+	}
+	dict.Insert(6, 5)
+	project := func(val int) float64 { return float64(val) }
+
+	t.Run("cursor aggregates", func(t *testing.T) {
+		if got := Count(dict.Range(3, 8)); got != 4 {
+			t.Errorf("got count = %v, want = %v", got, 4)
+		}
+		if got := Sum(dict.Range(3, 8), project); got != 185 {
+			t.Errorf("got sum = %v, want = %v", got, 185)
+		}
+		if got, ok := Avg(dict.Range(3, 8), project); !ok || got != 46.25 {
+			t.Errorf("got avg = %v, %v, want = %v, %v", got, ok, 46.25, true)
+		}
+		if got, ok := Min(dict.Range(3, 8), project); !ok || got != 5 {
+			t.Errorf("got min = %v, %v, want = %v, %v", got, ok, 5, true)
+		}
+		if got, ok := Max(dict.Range(3, 8), project); !ok || got != 80 {
+			t.Errorf("got max = %v, %v, want = %v, %v", got, ok, 80, true)
+		}
+		if _, ok := Avg(dict.Range(11, 20), project); ok {
+			t.Errorf("got avg ok = %v, want = %v", ok, false)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		lo, hi  int
+		count   int
+		min     int
+		max     int
+		nonZero bool
+	}{
+		{name: "inner", lo: 3, hi: 8, count: 4, min: 4, max: 8, nonZero: true},
+		{name: "exact bounds", lo: 2, hi: 10, count: 6, min: 2, max: 10, nonZero: true},
+		{name: "wide", lo: -5, hi: 50, count: 6, min: 2, max: 10, nonZero: true},
+		{name: "gap", lo: 7, hi: 7, count: 0},
+		{name: "above", lo: 11, hi: 20, count: 0},
+		{name: "below", lo: -3, hi: 1, count: 0},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dict.CountRange(tt.lo, tt.hi); got != tt.count {
+				t.Errorf("got count = %v, want = %v", got, tt.count)
+			}
+			minKey, ok := dict.MinKey(tt.lo, tt.hi)
+			if ok != tt.nonZero || (ok && minKey != tt.min) {
+				t.Errorf("got min key = %v, %v, want = %v, %v", minKey, ok, tt.min, tt.nonZero)
+			}
+			maxKey, ok := dict.MaxKey(tt.lo, tt.hi)
+			if ok != tt.nonZero || (ok && maxKey != tt.max) {
+				t.Errorf("got max key = %v, %v, want = %v, %v", maxKey, ok, tt.max, tt.nonZero)
+			}
+		})
+	}
+}
+
+func TestRankSelect(t *testing.T) {
+	one := 1
+	dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(one)), 7)
+	for key := 1; key <= 40; key++ {
+		dict.Insert(key, key)
+	}
+	// duplicates, a tall first node going away and a batch all move widths.
+	dict.Insert(10, 100)
+	dict.Insert(10, 101)
+	dict.DeleteValue(10, 10)
+	dict.DeleteRecord(20)
+	dict.DeleteBatch([]int{1, 30, 31})
+	dict.InsertBatch([]int{0, 35, 35}, []int{0, 350, 351})
+
+	var keys, vals []int
+	cursor := dict.AllRecords()
+	for cursor.Next(); cursor.HasNext(); cursor.Next() {
+		keys = append(keys, cursor.GetKey())
+		vals = append(vals, cursor.GetValue())
+	}
+	for i := range keys {
+		key, val, ok := dict.Select(i)
+		if !ok || key != keys[i] || val != vals[i] {
+			t.Errorf("got select(%v) = %v, %v, %v, want = %v, %v, %v", i, key, val, ok, keys[i], vals[i], true)
+		}
+	}
+	if _, _, ok := dict.Select(len(keys)); ok {
+		t.Errorf("got select past the end ok = %v, want = %v", ok, false)
+	}
+	if _, _, ok := dict.Select(-1); ok {
+		t.Errorf("got select(-1) ok = %v, want = %v", ok, false)
+	}
+
+	tests := []struct {
+		name  string
+		key   int
+		rank  int
+		lo    int
+		hi    int
+		count int
+	}{
+		{name: "first", key: 0, rank: 0, lo: 0, hi: 0, count: 1},
+		{name: "before duplicates", key: 10, rank: 9, lo: 10, hi: 10, count: 2},
+		{name: "after duplicates", key: 11, rank: 11, lo: 9, hi: 11, count: 4},
+		{name: "deleted key", key: 20, rank: 20, lo: 19, hi: 21, count: 2},
+		{name: "batch duplicates", key: 35, rank: 32, lo: 30, hi: 35, count: 6},
+		{name: "past the end", key: 99, rank: 40, lo: 41, hi: 99, count: 0},
+		{name: "reversed range", key: -1, rank: 0, lo: 5, hi: 4, count: 0},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dict.Rank(tt.key); got != tt.rank {
+				t.Errorf("got rank = %v, want = %v", got, tt.rank)
+			}
+			if got := dict.CountRange(tt.lo, tt.hi); got != tt.count {
+				t.Errorf("got count = %v, want = %v", got, tt.count)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package iondb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"testing"
+	"unsafe"
+)
+
+// benchBackends lists every handler the benchmarks run against. A handler
+// joins the table once it implements the dictionary operations.
+var benchBackends = []struct {
+	name string
+	init func(handler *IonDictionaryHandler)
+}{
+	{name: "skiplist", init: SldictInit},
+}
+
+type benchConfig struct {
+	kSize    IonKeySize
+	vSize    IonValueSize
+	dictSize IonDictionarySize
+	dist     string
+}
+
+func (bc benchConfig) String() string {
+	return fmt.Sprintf("k%d/v%d/d%d/%s", bc.kSize, bc.vSize, bc.dictSize, bc.dist)
+}
+
+// benchRecords is how many records a dictionary holds before the measured
+// operations start.
+const benchRecords = 1000
+
+// benchConfigs varies one of key size, value size, dictionary size and key
+// distribution at a time around the first configuration.
+func benchConfigs() []benchConfig {
+	base := benchConfig{kSize: 8, vSize: 8, dictSize: 7, dist: "random"}
+	configs := []benchConfig{base}
+	for _, kSize := range []IonKeySize{4} {
+		config := base
+		config.kSize = kSize
+		configs = append(configs, config)
+	}
+	for _, vSize := range []IonValueSize{64, 256} {
+		config := base
+		config.vSize = vSize
+		configs = append(configs, config)
+	}
+	for _, dictSize := range []IonDictionarySize{4, 16} {
+		config := base
+		config.dictSize = dictSize
+		configs = append(configs, config)
+	}
+	for _, dist := range []string{"sequential", "zipf"} {
+		config := base
+		config.dist = dist
+		configs = append(configs, config)
+	}
+	return configs
+}
+
+// benchKeys returns count keys of kSize bytes drawn from [0, benchRecords)
+// in the order of dist. Each key holds its number in host byte order.
+func benchKeys(kSize IonKeySize, dist string, count int) [][]byte {
+	r := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(r, 1.1, 1, benchRecords-1)
+	keys := make([][]byte, count)
+	for i := range keys {
+		var n uint64
+		switch dist {
+		case "sequential":
+			n = uint64(i % benchRecords)
+		case "zipf":
+			n = zipf.Uint64()
+		default:
+			n = uint64(r.Intn(benchRecords))
+		}
+		keys[i] = make([]byte, kSize)
+		buf := make([]byte, 8)
+		if hostIsBigEndian() {
+			binary.BigEndian.PutUint64(buf, n)
+			copy(keys[i], buf[8-kSize:])
+		} else {
+			binary.LittleEndian.PutUint64(buf, n)
+			copy(keys[i], buf[:kSize])
+		}
+	}
+	return keys
+}
+
+func benchKey(key []byte) IonKey {
+	return IonKey(unsafe.Pointer(&key[0]))
+}
+
+// benchDictionary creates a dictionary with the backend and fills it with
+// benchRecords sequential keys.
+func benchDictionary(b *testing.B, init func(handler *IonDictionaryHandler), config benchConfig) (*IonDictionary, []byte) {
+	var handler IonDictionaryHandler
+	dict := new(IonDictionary)
+	init(&handler)
+	if err := dictCreate(&handler, dict, -1, KeyTypeNumericSigned, config.kSize, config.vSize, config.dictSize); err != ErrOk {
+		b.Fatalf("got err = %v, want = %v", err, ErrOk)
+	}
+	val := make([]byte, config.vSize+1)
+	for _, key := range benchKeys(config.kSize, "sequential", benchRecords) {
+		dictInsert(dict, benchKey(key), IonValue(unsafe.Pointer(&val[0])))
+	}
+	return dict, val
+}
+
+func benchScan(dict *IonDictionary, predicate IonPredicate) int {
+	var cursor *IonDictCursor
+	var record IonRecord
+	record.key = IonKey(alloc(uintptr(dict.instance.record.keySize), nil))
+	record.value = IonValue(alloc(uintptr(dict.instance.record.valueSize), nil))
+	if dictFind(dict, predicate, &cursor) != ErrOk {
+		return 0
+	}
+	count := 0
+	for status := cursor.next(cursor, &record); status == csCursorActive; status = cursor.next(cursor, &record) {
+		count++
+	}
+	cursor.destroy(&cursor)
+	return count
+}
+
+func BenchmarkDictionary(b *testing.B) {
+	for _, backend := range benchBackends {
+		for _, config := range benchConfigs() {
+			name := backend.name + "/" + config.String()
+			keys := benchKeys(config.kSize, config.dist, benchRecords)
+			ordered := benchKeys(config.kSize, "sequential", benchRecords)
+
+			b.Run(name+"/insert", func(b *testing.B) {
+				dict, val := benchDictionary(b, backend.init, config)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					// start over from a full dictionary after every round of
+					// keys, so duplicate chains don't grow with b.N.
+					if i > 0 && i%benchRecords == 0 {
+						b.StopTimer()
+						dict, val = benchDictionary(b, backend.init, config)
+						b.StartTimer()
+					}
+					dictInsert(dict, benchKey(keys[i%benchRecords]), IonValue(unsafe.Pointer(&val[0])))
+				}
+			})
+
+			b.Run(name+"/get", func(b *testing.B) {
+				dict, val := benchDictionary(b, backend.init, config)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					dictGet(dict, benchKey(keys[i%benchRecords]), IonValue(unsafe.Pointer(&val[0])))
+				}
+			})
+
+			b.Run(name+"/update", func(b *testing.B) {
+				dict, val := benchDictionary(b, backend.init, config)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					dictUpdate(dict, benchKey(keys[i%benchRecords]), IonValue(unsafe.Pointer(&val[0])))
+				}
+			})
+
+			b.Run(name+"/delete", func(b *testing.B) {
+				dict, _ := benchDictionary(b, backend.init, config)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					// start over from a full dictionary after every round of keys.
+					if i > 0 && i%benchRecords == 0 {
+						b.StopTimer()
+						dict, _ = benchDictionary(b, backend.init, config)
+						b.StartTimer()
+					}
+					dictDelete(dict, benchKey(keys[i%benchRecords]))
+				}
+			})
+
+			b.Run(name+"/range", func(b *testing.B) {
+				dict, _ := benchDictionary(b, backend.init, config)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					// a range over about a tenth of the records.
+					lo := i % (benchRecords - benchRecords/10)
+					predicate := new(IonPredicateRange)
+					predicate.lowerBound = benchKey(ordered[lo])
+					predicate.upperBound = benchKey(ordered[lo+benchRecords/10])
+					benchScan(dict, predicate)
+				}
+			})
+
+			b.Run(name+"/scan", func(b *testing.B) {
+				dict, _ := benchDictionary(b, backend.init, config)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					benchScan(dict, new(IonPredicateAllRecords))
+				}
+			})
+		}
+	}
+}
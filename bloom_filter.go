@@ -0,0 +1,203 @@
+package iondb
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"unsafe"
+)
+
+// ionBloomFilter answers "definitely absent" for keys that were never added.
+// Deleted keys can't be taken out, so the filter counts deletes and is
+// rebuilt from the dictionary once they make up half of what was added.
+type ionBloomFilter struct {
+	bits    []uint64
+	m       uint64
+	k       uint32
+	items   int
+	deletes int
+	// kept so a rebuilt filter is sized like the original.
+	expectedItems int
+	fpRate        float64
+}
+
+func newBloomFilter(expectedItems int, fpRate float64) *ionBloomFilter {
+	if expectedItems < 1 {
+		expectedItems = 1
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.01
+	}
+	bf := new(ionBloomFilter)
+	bf.expectedItems = expectedItems
+	bf.fpRate = fpRate
+
+	// m = -n ln(p) / ln(2)^2, k = m/n ln(2)
+	m := math.Ceil(-float64(expectedItems) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	bf.m = (uint64(m) + 63) / 64 * 64
+	bf.k = uint32(math.Max(1, math.Round(float64(bf.m)/float64(expectedItems)*math.Ln2)))
+	bf.bits = make([]uint64, bf.m/64)
+	return bf
+}
+
+// bloomHash hashes the bytes of key twice with FNV-1a, the second time with
+// a different offset, for double hashing. Null terminated string keys hash
+// their contents.
+func bloomHash(kType IonKeyType, key IonKey, kSize IonKeySize) (uint64, uint64) {
+	var data []byte
+	if kType == KeyTypeNullTerminatedString {
+		data = []byte(*(*string)(key))
+	} else {
+		data = unsafe.Slice((*byte)(key), kSize)
+	}
+
+	h1 := uint64(14695981039346656037)
+	h2 := uint64(0x9e3779b97f4a7c15)
+	for _, b := range data {
+		h1 ^= uint64(b)
+		h1 *= 1099511628211
+		h2 ^= uint64(b)
+		h2 *= 1099511628211
+	}
+	// an odd step visits every bit position.
+	return h1, h2 | 1
+}
+
+func (bf *ionBloomFilter) add(h1 uint64, h2 uint64) {
+	for i := uint32(0); i < bf.k; i++ {
+		bit := (h1 + uint64(i)*h2) % bf.m
+		bf.bits[bit/64] |= 1 << (bit % 64)
+	}
+	bf.items++
+}
+
+func (bf *ionBloomFilter) mayContain(h1 uint64, h2 uint64) bool {
+	for i := uint32(0); i < bf.k; i++ {
+		bit := (h1 + uint64(i)*h2) % bf.m
+		if bf.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (bf *ionBloomFilter) reset() {
+	for i := range bf.bits {
+		bf.bits[i] = 0
+	}
+	bf.items = 0
+	bf.deletes = 0
+}
+
+func (bf *ionBloomFilter) stale() bool {
+	return bf.deletes > 0 && bf.deletes*2 >= bf.items
+}
+
+// bloomFilterSize is how many bytes writeTo stores a filter of m bits in.
+func bloomFilterSize(m uint64) int64 {
+	return 32 + int64(m/8)
+}
+
+// writeTo stores the filter so it can be saved with the dictionary:
+// m u64 | k u32 | items u32 | deletes u32 | expectedItems u32 | fpRate f64 | bits.
+func (bf *ionBloomFilter) writeTo(w io.Writer) IonErr {
+	buf := make([]byte, bloomFilterSize(bf.m))
+	binary.LittleEndian.PutUint64(buf[0:8], bf.m)
+	binary.LittleEndian.PutUint32(buf[8:12], bf.k)
+	binary.LittleEndian.PutUint32(buf[12:16], uint32(bf.items))
+	binary.LittleEndian.PutUint32(buf[16:20], uint32(bf.deletes))
+	binary.LittleEndian.PutUint32(buf[20:24], uint32(bf.expectedItems))
+	binary.LittleEndian.PutUint64(buf[24:32], math.Float64bits(bf.fpRate))
+	for i, word := range bf.bits {
+		binary.LittleEndian.PutUint64(buf[32+i*8:], word)
+	}
+	if _, err := w.Write(buf); err != nil {
+		return ErrFileWriteError
+	}
+	return ErrOk
+}
+
+func readBloomFilter(r io.Reader) (*ionBloomFilter, IonErr) {
+	header := make([]byte, 32)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrFileReadError
+	}
+	bf := new(ionBloomFilter)
+	bf.m = binary.LittleEndian.Uint64(header[0:8])
+	bf.k = binary.LittleEndian.Uint32(header[8:12])
+	bf.items = int(binary.LittleEndian.Uint32(header[12:16]))
+	bf.deletes = int(binary.LittleEndian.Uint32(header[16:20]))
+	bf.expectedItems = int(binary.LittleEndian.Uint32(header[20:24]))
+	bf.fpRate = math.Float64frombits(binary.LittleEndian.Uint64(header[24:32]))
+	if bf.m == 0 || bf.m%64 != 0 || bf.k == 0 {
+		return nil, ErrCorruptedData
+	}
+
+	words := make([]byte, bf.m/8)
+	if _, err := io.ReadFull(r, words); err != nil {
+		return nil, ErrFileReadError
+	}
+	bf.bits = make([]uint64, bf.m/64)
+	for i := range bf.bits {
+		bf.bits[i] = binary.LittleEndian.Uint64(words[i*8:])
+	}
+	return bf, ErrOk
+}
+
+// dictBloomAdd records key in the dictionary's filter, if it has one.
+func dictBloomAdd(dict *IonDictionary, key IonKey) {
+	bf := dict.instance.bloom
+	if bf == nil {
+		return
+	}
+	bf.add(bloomHash(dict.instance.kType, key, dict.instance.record.keySize))
+}
+
+// dictBloomMayContain is false only if key was never added to the dictionary.
+func dictBloomMayContain(dict *IonDictionary, key IonKey) bool {
+	bf := dict.instance.bloom
+	if bf == nil {
+		return true
+	}
+	return bf.mayContain(bloomHash(dict.instance.kType, key, dict.instance.record.keySize))
+}
+
+// dictBloomDeleted counts deleted records and rebuilds the filter once they
+// pile up.
+func dictBloomDeleted(dict *IonDictionary, count IonResultCount) {
+	bf := dict.instance.bloom
+	if bf == nil || count <= 0 {
+		return
+	}
+	bf.deletes += int(count)
+	dictBloomCheck(dict)
+}
+
+// dictBloomExpired counts a record a handler removed because it expired.
+// Handlers may do that while a cursor walks them, so the filter is only
+// rebuilt by the next dictBloomCheck.
+func dictBloomExpired(parent *IonDictionaryParent) {
+	if bf := parent.bloom; bf != nil {
+		bf.deletes++
+	}
+}
+
+// dictBloomCheck rebuilds the filter once deletes make up half of it.
+func dictBloomCheck(dict *IonDictionary) {
+	if bf := dict.instance.bloom; bf != nil && bf.stale() {
+		dictBloomRebuild(dict)
+	}
+}
+
+// dictBloomRebuild refills the filter from every record of the dictionary.
+func dictBloomRebuild(dict *IonDictionary) IonErr {
+	bf := dict.instance.bloom
+	if bf == nil {
+		return ErrOk
+	}
+	bf.reset()
+	return dictForEachRecord(dict, func(record *IonRecord) IonErr {
+		bf.add(bloomHash(dict.instance.kType, record.key, dict.instance.record.keySize))
+		return ErrOk
+	})
+}
@@ -0,0 +1,198 @@
+package iondb
+
+import (
+	"bytes"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestBloomFilterFalsePositiveRate(t *testing.T) {
+	one := 1
+	bf := newBloomFilter(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		bf.add(bloomHash(KeyTypeNumericSigned, IonKey(&i), int(unsafe.Sizeof(one))))
+	}
+
+	t.Run("no false negatives", func(t *testing.T) {
+		for i := 0; i < 1000; i++ {
+			if !bf.mayContain(bloomHash(KeyTypeNumericSigned, IonKey(&i), int(unsafe.Sizeof(one)))) {
+				t.Errorf("got absent for key = %v, want = %v", i, "present")
+			}
+		}
+	})
+
+	t.Run("false positive rate", func(t *testing.T) {
+		positives := 0
+		for i := 1000; i < 11000; i++ {
+			if bf.mayContain(bloomHash(KeyTypeNumericSigned, IonKey(&i), int(unsafe.Sizeof(one)))) {
+				positives++
+			}
+		}
+		if positives > 300 {
+			t.Errorf("got false positives = %v, want <= %v", positives, 300)
+		}
+	})
+
+	t.Run("write and read", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := bf.writeTo(&buf); err != ErrOk {
+			t.Fatalf("got err = %v, want = %v", err, ErrOk)
+		}
+		read, err := readBloomFilter(&buf)
+		if err != ErrOk {
+			t.Fatalf("got err = %v, want = %v", err, ErrOk)
+		}
+		if read.m != bf.m || read.k != bf.k || read.items != bf.items {
+			t.Errorf("got m, k, items = %v, %v, %v, want = %v, %v, %v", read.m, read.k, read.items, bf.m, bf.k, bf.items)
+		}
+		for i := range bf.bits {
+			if read.bits[i] != bf.bits[i] {
+				t.Fatalf("got bits[%v] = %v, want = %v", i, read.bits[i], bf.bits[i])
+			}
+		}
+	})
+}
+
+func TestSkipListBloomFilter(t *testing.T) {
+	one := 1
+	dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(one)), 7, WithBloomFilter(100, 0.01))
+	for i := 0; i < 100; i++ {
+		dict.Insert(i, i)
+	}
+
+	t.Run("absent keys skip the handler", func(t *testing.T) {
+		hidden := 500
+		slInsert((*ionSkipList)(unsafe.Pointer(dict.dict.instance)), IonKey(&hidden), IonValue(&hidden))
+		dict.Get(hidden)
+		if dict.LastStatus.Err != ErrItemNotFound {
+			t.Errorf("got err = %v, want = %v", dict.LastStatus.Err, ErrItemNotFound)
+		}
+		if got := dict.Get(42); got != 42 {
+			t.Errorf("got val = %v, want = %v", got, 42)
+		}
+		dict.DeleteRecord(hidden)
+	})
+
+	t.Run("rebuilt after deletes", func(t *testing.T) {
+		for i := 0; i < 60; i++ {
+			dict.DeleteRecord(i)
+		}
+		// with the hidden key, deleting 48 makes 50 deletes and rebuilds the
+		// filter from the 51 keys left.
+		bf := dict.dict.instance.bloom
+		if bf.items != 51 {
+			t.Errorf("got items = %v, want = %v", bf.items, 51)
+		}
+		if bf.deletes != 11 {
+			t.Errorf("got deletes = %v, want = %v", bf.deletes, 11)
+		}
+		if got := dict.Get(80); got != 80 {
+			t.Errorf("got val = %v, want = %v", got, 80)
+		}
+	})
+
+	t.Run("not shared by config", func(t *testing.T) {
+		conf := dict.Config()
+		if conf.bloomItems != 100 || conf.bloomFPRate != 0.01 {
+			t.Errorf("got bloom items, rate = %v, %v, want = %v, %v", conf.bloomItems, conf.bloomFPRate, 100, 0.01)
+		}
+		var other IonDictionary
+		var handler IonDictionaryHandler
+		SldictInit(&handler)
+		dictCreate(&handler, &other, 2, KeyTypeNumericSigned, conf.kSize, conf.vSize, 7)
+		dictApplyConfig(&other, &conf)
+		if other.instance.bloom == nil || other.instance.bloom == dict.dict.instance.bloom {
+			t.Errorf("got bloom = %p, want a new filter", other.instance.bloom)
+		}
+		// the filter comes back as it was instead of from a scan of other.
+		saved, restored := dict.dict.instance.bloom, other.instance.bloom
+		if restored.items != saved.items || restored.deletes != saved.deletes {
+			t.Errorf("got items, deletes = %v, %v, want = %v, %v", restored.items, restored.deletes, saved.items, saved.deletes)
+		}
+		for i := range saved.bits {
+			if restored.bits[i] != saved.bits[i] {
+				t.Fatalf("got bits[%v] = %v, want = %v", i, restored.bits[i], saved.bits[i])
+			}
+		}
+	})
+
+	t.Run("saved with export", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := dict.Export(&buf); err != ErrOk {
+			t.Fatalf("got err = %v, want = %v", err, ErrOk)
+		}
+		saved := dict.dict.instance.bloom
+
+		copied := NewSkipList[int, int](-1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(one)), 7, WithBloomFilter(100, 0.01))
+		if err := copied.Import(bytes.NewReader(buf.Bytes())); err != ErrOk {
+			t.Fatalf("got err = %v, want = %v", err, ErrOk)
+		}
+		bf := copied.dict.instance.bloom
+		if bf == saved || bf.items != saved.items || bf.deletes != saved.deletes {
+			t.Errorf("got items, deletes = %v, %v, want a copy with %v, %v", bf.items, bf.deletes, saved.items, saved.deletes)
+		}
+		for i := range saved.bits {
+			if bf.bits[i] != saved.bits[i] {
+				t.Fatalf("got bits[%v] = %v, want = %v", i, bf.bits[i], saved.bits[i])
+			}
+		}
+		if got := copied.Get(80); got != 80 {
+			t.Errorf("got val = %v, want = %v", got, 80)
+		}
+
+		// a filter of another size is filled from the records instead.
+		resized := NewSkipList[int, int](-1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(one)), 7, WithBloomFilter(1000, 0.01))
+		if err := resized.Import(bytes.NewReader(buf.Bytes())); err != ErrOk {
+			t.Fatalf("got err = %v, want = %v", err, ErrOk)
+		}
+		if bf := resized.dict.instance.bloom; bf.items != 40 || bf.deletes != 0 {
+			t.Errorf("got items, deletes = %v, %v, want = %v, %v", bf.items, bf.deletes, 40, 0)
+		}
+		plain := NewSkipList[int, int](-1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(one)), 7)
+		if err := plain.Import(bytes.NewReader(buf.Bytes())); err != ErrOk || plain.Get(80) != 80 {
+			t.Errorf("got err = %v, want = %v", err, ErrOk)
+		}
+	})
+}
+
+func TestSkipListBloomFilterExpiry(t *testing.T) {
+	one := 1
+	now := time.Unix(1000, 0)
+	clock := func() time.Time { return now }
+
+	tests := []struct {
+		name        string
+		remove      func(dict *SkipList[int, int])
+		wantItems   int
+		wantDeletes int
+	}{
+		{"sweep", func(dict *SkipList[int, int]) { dict.Sweep() }, 9, 0},
+		{"cursor", func(dict *SkipList[int, int]) {
+			cursor := dict.AllRecords()
+			for cursor.Next(); cursor.HasNext(); cursor.Next() {
+			}
+			// the cursor only counts what it unlinked, the insert rebuilds.
+			dict.Insert(100, 100)
+		}, 10, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(one)), 7, WithBloomFilter(100, 0.01), WithClock(clock))
+			for i := 0; i < 9; i++ {
+				dict.InsertWithTTL(i, i, 0)
+			}
+			for i := 9; i < 20; i++ {
+				dict.InsertWithTTL(i, i, time.Second)
+			}
+			now = now.Add(2 * time.Second)
+			defer func() { now = time.Unix(1000, 0) }()
+
+			tt.remove(dict)
+			bf := dict.dict.instance.bloom
+			if bf.items != tt.wantItems || bf.deletes != tt.wantDeletes {
+				t.Errorf("got items, deletes = %v, %v, want = %v, %v", bf.items, bf.deletes, tt.wantItems, tt.wantDeletes)
+			}
+		})
+	}
+}
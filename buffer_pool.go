@@ -0,0 +1,590 @@
+package iondb
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+// IonFile is the storage a file-backed handler keeps its pages in.
+type IonFile interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+// IonEvictionPolicy selects which unpinned frame a buffer pool reuses when it is full.
+type IonEvictionPolicy int8
+
+const (
+	EvictionLRU = iota
+	EvictionClock
+)
+
+const bpNoFrame = -1
+
+// bpChecksumSize is the trailer a page keeps its CRC32 in when the pool
+// checksums pages.
+const bpChecksumSize = 4
+
+// A pool that checksums or encrypts pages starts every file with a header
+// page that holds how many pages have been written to it, so a page below
+// that count that reads as zeros or is cut off was zeroed or truncated and
+// not just never written. An encrypted file keeps its journal page after
+// the header. Page pageNo of the file follows the reserved pages.
+
+// bpCountSize is the room the page count takes in a header, and so the
+// least a page must leave a handler once its checksum and seal are taken.
+const bpCountSize = 8
+
+// BufferPoolStats reports how well a buffer pool is sized for its workload.
+type BufferPoolStats struct {
+	Hits       int
+	Misses     int
+	Evictions  int
+	WriteBacks int
+	// Corruptions counts pages read with a checksum that did not match.
+	Corruptions int
+}
+
+// BufferPool caches fixed-size pages of file-backed dictionaries in a
+// bounded number of frames, so repeated get and find steps don't go to the
+// file every time. One pool can be shared by every dictionary on a device.
+type BufferPool struct {
+	pageSize int
+	policy   IonEvictionPolicy
+	// checksums is set when every page ends with a CRC32 of the rest of it.
+	checksums bool
+	// encrypted is set when pages are sealed with the key of their file.
+	encrypted bool
+	keys      map[IonFile]cipher.AEAD
+	sealBuf   []byte
+	// written is how many pages of each encrypted file have been written.
+	written map[IonFile]int64
+	frames  []ionPageFrame
+	table   map[ionPageID]int
+	// LRU list of frame indexes, most recently used first.
+	lruHead int
+	lruTail int
+	// clock hand for EvictionClock.
+	hand  int
+	stats BufferPoolStats
+}
+
+type ionPageID struct {
+	file   IonFile
+	pageNo int64
+}
+
+type ionPageFrame struct {
+	id       ionPageID
+	data     []byte
+	valid    bool
+	dirty    bool
+	pinCount int
+	refBit   bool
+	prev     int
+	next     int
+}
+
+// IonPage is a pinned page handed out by the buffer pool. Data stays valid
+// until the page is unpinned.
+type IonPage struct {
+	frame  int
+	PageNo int64
+	Data   []byte
+}
+
+// NewBufferPool returns a pool of pageCount frames of pageSize bytes, or
+// ErrInvalidiInitialSize if either is not positive.
+func NewBufferPool(pageSize int, pageCount int, policy IonEvictionPolicy) (*BufferPool, IonErr) {
+	if pageSize <= 0 || pageCount <= 0 {
+		return nil, ErrInvalidiInitialSize
+	}
+	bp := new(BufferPool)
+	bp.pageSize = pageSize
+	bp.policy = policy
+	bp.frames = make([]ionPageFrame, pageCount)
+	bp.table = make(map[ionPageID]int, pageCount)
+
+	// a single backing array keeps the pool to one allocation.
+	backing := make([]byte, pageSize*pageCount)
+	for i := range bp.frames {
+		bp.frames[i].data = backing[i*pageSize : (i+1)*pageSize : (i+1)*pageSize]
+		bp.frames[i].prev = bpNoFrame
+		bp.frames[i].next = bpNoFrame
+	}
+	bp.lruHead = bpNoFrame
+	bp.lruTail = bpNoFrame
+	return bp, ErrOk
+}
+
+func (bp *BufferPool) Stats() BufferPoolStats {
+	return bp.stats
+}
+
+// PageSize is how many bytes of a page a handler can use, the page size
+// less the checksum trailer and the encryption nonce and tag of pages that
+// have them.
+func (bp *BufferPool) PageSize() int {
+	if bp.checksums {
+		return bp.plainSize() - bpChecksumSize
+	}
+	return bp.plainSize()
+}
+
+// plainSize is how many bytes of a page are stored in it, checksum
+// included, once the page is decrypted.
+func (bp *BufferPool) plainSize() int {
+	if bp.encrypted {
+		return bp.pageSize - bpSealOverhead
+	}
+	return bp.pageSize
+}
+
+// EnableChecksums makes the pool end every page it writes with a CRC32 of
+// the page and check it on every page it reads, so torn writes and bit rot
+// are reported as ErrCorruptedData. It must be called before the first page
+// is read. A pool whose pages would leave less than bpCountSize bytes once
+// the checksum is taken gives ErrInvalidiInitialSize.
+func (bp *BufferPool) EnableChecksums() IonErr {
+	bp.checksums = true
+	if bp.PageSize() < bpCountSize {
+		bp.checksums = false
+		return ErrInvalidiInitialSize
+	}
+	if bp.written == nil {
+		bp.written = make(map[IonFile]int64)
+	}
+	return ErrOk
+}
+
+// reserved is how many pages a file starts with before its page 0.
+func (bp *BufferPool) reserved() int64 {
+	switch {
+	case bp.encrypted:
+		return 2
+	case bp.checksums:
+		return 1
+	}
+	return 0
+}
+
+// headerPage is the number the header of a file is kept, and sealed, as.
+func (bp *BufferPool) headerPage() int64 {
+	return -bp.reserved()
+}
+
+// offset is where page pageNo starts in file, past its reserved pages.
+func (bp *BufferPool) offset(pageNo int64) int64 {
+	return (pageNo + bp.reserved()) * int64(bp.pageSize)
+}
+
+// pagesWritten is how many pages have been written to file, read from its
+// header the first time the pool touches the file. A new file is given a
+// header then. An encrypted file has its header read by SetKey.
+func (bp *BufferPool) pagesWritten(file IonFile) (int64, IonErr) {
+	if bp.written == nil {
+		return 0, ErrOk
+	}
+	if written, ok := bp.written[file]; ok {
+		return written, ErrOk
+	}
+	if bp.encrypted {
+		return 0, ErrUninitialized
+	}
+	if err := bp.readHeader(file, nil); err != ErrOk {
+		return 0, err
+	}
+	return bp.written[file], ErrOk
+}
+
+// readHeader reads how many pages have been written to file from its
+// header, opened under aead if the pool encrypts pages.
+func (bp *BufferPool) readHeader(file IonFile, aead cipher.AEAD) IonErr {
+	page := make([]byte, bp.pageSize)
+	n, err := file.ReadAt(page, bp.offset(bp.headerPage()))
+	if err != nil && err != io.EOF {
+		return ErrFileReadError
+	}
+	for i := n; i < len(page); i++ {
+		page[i] = 0
+	}
+	probe := make([]byte, 1)
+	if n, _ := file.ReadAt(probe, bp.offset(bp.headerPage()+1)); n == 0 && bpZeroPage(page) {
+		return bp.writeHeader(file, aead, 0)
+	}
+	plain, oerr := bp.openHeader(aead, page)
+	if oerr != ErrOk {
+		// torn by a rotation to key that got as far as the header.
+		if aead == nil || bp.readJournal(file, aead, bp.headerPage(), page) != ErrOk {
+			return ErrCorruptedData
+		}
+		if _, err := file.WriteAt(page, bp.offset(bp.headerPage())); err != nil {
+			return ErrFileWriteError
+		}
+		plain, _ = bp.openHeader(aead, page)
+	}
+	bp.written[file] = int64(binary.LittleEndian.Uint64(plain))
+	return ErrOk
+}
+
+// writeHeader writes written, the number of pages written to file, as its
+// header, sealed under aead if the pool encrypts pages.
+func (bp *BufferPool) writeHeader(file IonFile, aead cipher.AEAD, written int64) IonErr {
+	page, err := bp.sealHeader(aead, written)
+	if err != ErrOk {
+		return err
+	}
+	if _, err := file.WriteAt(page, bp.offset(bp.headerPage())); err != nil {
+		return ErrFileWriteError
+	}
+	bp.written[file] = written
+	return ErrOk
+}
+
+func (bp *BufferPool) sealHeader(aead cipher.AEAD, written int64) ([]byte, IonErr) {
+	plain := make([]byte, bp.plainSize())
+	binary.LittleEndian.PutUint64(plain, uint64(written))
+	if bp.checksums {
+		trailer := len(plain) - bpChecksumSize
+		binary.LittleEndian.PutUint32(plain[trailer:], crc32.ChecksumIEEE(plain[:trailer]))
+	}
+	if aead == nil {
+		return plain, ErrOk
+	}
+	page := make([]byte, bp.pageSize)
+	if err := bpSealPage(aead, bp.headerPage(), plain, page); err != ErrOk {
+		return nil, err
+	}
+	return page, ErrOk
+}
+
+func (bp *BufferPool) openHeader(aead cipher.AEAD, page []byte) ([]byte, IonErr) {
+	plain := page
+	if aead != nil {
+		plain = make([]byte, bp.plainSize())
+		if err := bpOpenPage(aead, bp.headerPage(), page, plain); err != ErrOk {
+			return nil, err
+		}
+	}
+	if bp.checksums && !bpPageChecksumOk(plain) {
+		return nil, ErrCorruptedData
+	}
+	return plain, ErrOk
+}
+
+func (bp *BufferPool) ResetStats() {
+	bp.stats = BufferPoolStats{}
+}
+
+// Pin returns the page pageNo of file, reading it in on a miss. Reads past
+// the end of the file give a zeroed page. The page must be unpinned when the
+// caller is done with it.
+func (bp *BufferPool) Pin(file IonFile, pageNo int64) (*IonPage, IonErr) {
+	id := ionPageID{file, pageNo}
+	if idx, ok := bp.table[id]; ok {
+		bp.stats.Hits++
+		bp.touch(idx)
+		bp.frames[idx].pinCount++
+		return &IonPage{idx, pageNo, bp.frames[idx].data[:bp.PageSize()]}, ErrOk
+	}
+
+	bp.stats.Misses++
+	idx, err := bp.victim()
+	if err != ErrOk {
+		return nil, err
+	}
+	frame := &bp.frames[idx]
+	if frame.valid {
+		if err := bp.writeBack(idx); err != ErrOk {
+			return nil, err
+		}
+		delete(bp.table, frame.id)
+		bp.stats.Evictions++
+	}
+
+	if err := bp.readPage(file, pageNo, frame.data); err != ErrOk {
+		frame.valid = false
+		bp.unlink(idx)
+		if err == ErrCorruptedData {
+			bp.stats.Corruptions++
+		}
+		return nil, err
+	}
+
+	frame.id = id
+	frame.valid = true
+	frame.dirty = false
+	frame.pinCount = 1
+	bp.table[id] = idx
+	bp.touch(idx)
+	return &IonPage{idx, pageNo, frame.data[:bp.PageSize()]}, ErrOk
+}
+
+// Unpin releases a page returned by Pin. dirty marks the page for write-back.
+func (bp *BufferPool) Unpin(page *IonPage, dirty bool) {
+	frame := &bp.frames[page.frame]
+	if frame.pinCount > 0 {
+		frame.pinCount--
+	}
+	if dirty {
+		frame.dirty = true
+	}
+	page.Data = nil
+}
+
+// Flush writes every dirty page of file back. A file-backed handler calls
+// it from closeDictionary.
+func (bp *BufferPool) Flush(file IonFile) IonErr {
+	for idx := range bp.frames {
+		if bp.frames[idx].valid && bp.frames[idx].id.file == file {
+			if err := bp.writeBack(idx); err != ErrOk {
+				return err
+			}
+		}
+	}
+	return ErrOk
+}
+
+// Drop flushes file, gives its frames back to the pool and forgets its key.
+// A file-backed handler calls it when it deletes the dictionary.
+func (bp *BufferPool) Drop(file IonFile) IonErr {
+	if err := bp.Flush(file); err != ErrOk {
+		return err
+	}
+	for idx := range bp.frames {
+		frame := &bp.frames[idx]
+		if frame.valid && frame.id.file == file {
+			delete(bp.table, frame.id)
+			frame.valid = false
+			frame.pinCount = 0
+			frame.refBit = false
+			frame.id = ionPageID{}
+			bp.unlink(idx)
+		}
+	}
+	delete(bp.keys, file)
+	delete(bp.written, file)
+	return ErrOk
+}
+
+func (bp *BufferPool) writeBack(idx int) IonErr {
+	frame := &bp.frames[idx]
+	if !frame.dirty {
+		return ErrOk
+	}
+	if err := bp.writePage(frame.id.file, frame.id.pageNo, frame.data); err != ErrOk {
+		return err
+	}
+	frame.dirty = false
+	bp.stats.WriteBacks++
+	return ErrOk
+}
+
+// readPage reads page pageNo of file into data, a frame long, decrypting
+// and checking it as the pool is set up to. A page past the pages written
+// to the file that reads as zeros, or past its end, gives a zeroed page.
+func (bp *BufferPool) readPage(file IonFile, pageNo int64, data []byte) IonErr {
+	written, err := bp.pagesWritten(file)
+	if err != ErrOk {
+		return err
+	}
+	buf := data
+	if bp.encrypted {
+		buf = bp.sealBuf
+	}
+	n, rerr := file.ReadAt(buf, bp.offset(pageNo))
+	if rerr != nil && rerr != io.EOF {
+		return ErrFileReadError
+	}
+	for i := n; i < len(buf); i++ {
+		buf[i] = 0
+	}
+	if pageNo >= written && bpZeroPage(buf) {
+		for i := range data {
+			data[i] = 0
+		}
+		return ErrOk
+	}
+	if bp.encrypted {
+		if err := bp.open(file, pageNo, buf, data); err != ErrOk {
+			return err
+		}
+	}
+	if bp.checksums && !bpPageChecksumOk(data[:bp.plainSize()]) {
+		return ErrCorruptedData
+	}
+	return ErrOk
+}
+
+// writePage writes data, a frame, as page pageNo of file, adding the
+// checksum and encrypting it as the pool is set up to.
+func (bp *BufferPool) writePage(file IonFile, pageNo int64, data []byte) IonErr {
+	if err := bp.putPage(file, pageNo, data); err != ErrOk {
+		return err
+	}
+	written, err := bp.pagesWritten(file)
+	if err != ErrOk {
+		return err
+	}
+	// the page goes before the count that covers it, so a crash between
+	// the two never leaves a count over a page that was not written.
+	if bp.written != nil && pageNo >= written {
+		for gap := written; gap < pageNo; gap++ {
+			if err := bp.fillPage(file, gap); err != ErrOk {
+				return err
+			}
+		}
+		return bp.writeHeader(file, bp.keys[file], pageNo+1)
+	}
+	return ErrOk
+}
+
+// fillPage writes a page of zeros as page pageNo of file, skipped over by
+// a write further on, so it is not taken for a page zeroed after it was
+// written. A page already there, left by a write the count did not cover
+// before a crash, is kept.
+func (bp *BufferPool) fillPage(file IonFile, pageNo int64) IonErr {
+	data := make([]byte, bp.pageSize)
+	if _, err := file.ReadAt(data, bp.offset(pageNo)); err != nil && err != io.EOF {
+		return ErrFileReadError
+	}
+	if !bpZeroPage(data) {
+		return ErrOk
+	}
+	return bp.putPage(file, pageNo, data)
+}
+
+func (bp *BufferPool) putPage(file IonFile, pageNo int64, data []byte) IonErr {
+	if bp.checksums {
+		trailer := bp.plainSize() - bpChecksumSize
+		binary.LittleEndian.PutUint32(data[trailer:], crc32.ChecksumIEEE(data[:trailer]))
+	}
+	buf := data
+	if bp.encrypted {
+		if err := bp.seal(file, pageNo, data[:bp.plainSize()], bp.sealBuf); err != ErrOk {
+			return err
+		}
+		buf = bp.sealBuf
+	}
+	if _, err := file.WriteAt(buf, bp.offset(pageNo)); err != nil {
+		return ErrFileWriteError
+	}
+	return ErrOk
+}
+
+// Verify reads every page of file straight from the file, past the pool,
+// and returns the numbers of the pages whose checksum or encryption tag
+// does not match, so the readable pages can be salvaged. Pages that are
+// missing or zeroed below the count in the header of the file are bad too.
+// Pages the pool has not written back yet are checked as they are on the
+// file.
+func (bp *BufferPool) Verify(file IonFile) ([]int64, IonErr) {
+	if !bp.checksums && !bp.encrypted {
+		return nil, ErrNotImplemented
+	}
+	written, err := bp.pagesWritten(file)
+	if err != ErrOk {
+		return nil, err
+	}
+	var bad []int64
+	probe := make([]byte, 1)
+	data := make([]byte, bp.pageSize)
+	for pageNo := int64(0); ; pageNo++ {
+		// a page starts inside the file if its first byte does.
+		if n, err := file.ReadAt(probe, bp.offset(pageNo)); n == 0 && pageNo >= written {
+			if err != nil && err != io.EOF {
+				return bad, ErrFileReadError
+			}
+			return bad, ErrOk
+		}
+		switch err := bp.readPage(file, pageNo, data); err {
+		case ErrOk:
+		case ErrCorruptedData:
+			bad = append(bad, pageNo)
+		default:
+			return bad, err
+		}
+	}
+}
+
+// bpPageChecksumOk checks the trailer of a page read from a file. The CRC32
+// of zeros is not zero, so a written page that was zeroed fails.
+func bpPageChecksumOk(data []byte) bool {
+	trailer := len(data) - bpChecksumSize
+	return binary.LittleEndian.Uint32(data[trailer:]) == crc32.ChecksumIEEE(data[:trailer])
+}
+
+// victim picks a frame to load a new page into: an empty frame if there is
+// one, otherwise an unpinned frame chosen by the eviction policy.
+func (bp *BufferPool) victim() (int, IonErr) {
+	for idx := range bp.frames {
+		if !bp.frames[idx].valid {
+			return idx, ErrOk
+		}
+	}
+
+	switch bp.policy {
+	case EvictionClock:
+		// two full sweeps: the first may only clear reference bits.
+		for i := 0; i < 2*len(bp.frames); i++ {
+			idx := bp.hand
+			bp.hand = (bp.hand + 1) % len(bp.frames)
+			frame := &bp.frames[idx]
+			if frame.pinCount > 0 {
+				continue
+			}
+			if frame.refBit {
+				frame.refBit = false
+				continue
+			}
+			return idx, ErrOk
+		}
+	default:
+		for idx := bp.lruTail; idx != bpNoFrame; idx = bp.frames[idx].prev {
+			if bp.frames[idx].pinCount == 0 {
+				return idx, ErrOk
+			}
+		}
+	}
+	return bpNoFrame, ErrOutOfMemory
+}
+
+// touch records an access to the frame for the eviction policy.
+func (bp *BufferPool) touch(idx int) {
+	if bp.policy == EvictionClock {
+		bp.frames[idx].refBit = true
+		return
+	}
+	bp.unlink(idx)
+	frame := &bp.frames[idx]
+	frame.prev = bpNoFrame
+	frame.next = bp.lruHead
+	if bp.lruHead != bpNoFrame {
+		bp.frames[bp.lruHead].prev = idx
+	}
+	bp.lruHead = idx
+	if bp.lruTail == bpNoFrame {
+		bp.lruTail = idx
+	}
+}
+
+func (bp *BufferPool) unlink(idx int) {
+	frame := &bp.frames[idx]
+	if bp.lruHead != idx && frame.prev == bpNoFrame && frame.next == bpNoFrame {
+		return
+	}
+	if frame.prev != bpNoFrame {
+		bp.frames[frame.prev].next = frame.next
+	} else {
+		bp.lruHead = frame.next
+	}
+	if frame.next != bpNoFrame {
+		bp.frames[frame.next].prev = frame.prev
+	} else {
+		bp.lruTail = frame.prev
+	}
+	frame.prev = bpNoFrame
+	frame.next = bpNoFrame
+}
@@ -0,0 +1,346 @@
+package iondb
+
+import (
+	"io"
+	"testing"
+)
+
+type memFile struct {
+	data   []byte
+	reads  int
+	writes int
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	f.reads++
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) WriteAt(p []byte, off int64) (int, error) {
+	f.writes++
+	if end := off + int64(len(p)); end > int64(len(f.data)) {
+		grown := make([]byte, end)
+		copy(grown, f.data)
+		f.data = grown
+	}
+	return copy(f.data[off:], p), nil
+}
+
+func newPagedMemFile(pageSize int, pages int) *memFile {
+	f := new(memFile)
+	f.data = make([]byte, pageSize*pages)
+	for i := 0; i < pages; i++ {
+		f.data[i*pageSize] = byte(i)
+	}
+	return f
+}
+
+func TestNewBufferPool(t *testing.T) {
+	tests := []struct {
+		name      string
+		pageSize  int
+		pageCount int
+		want      IonErr
+	}{
+		{name: "valid", pageSize: 16, pageCount: 2, want: ErrOk},
+		{name: "zero page size", pageSize: 0, pageCount: 2, want: ErrInvalidiInitialSize},
+		{name: "negative page size", pageSize: -16, pageCount: 2, want: ErrInvalidiInitialSize},
+		{name: "zero page count", pageSize: 16, pageCount: 0, want: ErrInvalidiInitialSize},
+		{name: "negative page count", pageSize: 16, pageCount: -1, want: ErrInvalidiInitialSize},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			bp, err := NewBufferPool(tt.pageSize, tt.pageCount, EvictionLRU)
+			if err != tt.want {
+				t.Errorf("got err = %v, want = %v", err, tt.want)
+			}
+			if (bp != nil) != (tt.want == ErrOk) {
+				t.Errorf("got pool = %v, want one only when err = %v", bp, ErrOk)
+			}
+		})
+	}
+}
+
+func TestBufferPoolHitMiss(t *testing.T) {
+	file := newPagedMemFile(16, 4)
+	bp, _ := NewBufferPool(16, 2, EvictionLRU)
+
+	t.Run("hit and miss", func(t *testing.T) {
+		for _, pageNo := range []int64{0, 0, 1, 0} {
+			page, err := bp.Pin(file, pageNo)
+			if err != ErrOk {
+				t.Fatalf("got err = %v, want = %v", err, ErrOk)
+			}
+			if page.Data[0] != byte(pageNo) {
+				t.Errorf("got page byte = %v, want = %v", page.Data[0], pageNo)
+			}
+			bp.Unpin(page, false)
+		}
+		stats := bp.Stats()
+		if stats.Hits != 2 {
+			t.Errorf("got hits = %v, want = %v", stats.Hits, 2)
+		}
+		if stats.Misses != 2 {
+			t.Errorf("got misses = %v, want = %v", stats.Misses, 2)
+		}
+		if file.reads != 2 {
+			t.Errorf("got reads = %v, want = %v", file.reads, 2)
+		}
+	})
+}
+
+func TestBufferPoolEviction(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  IonEvictionPolicy
+		access  []int64
+		resided int64
+		evicted int64
+	}{
+		{
+			name:    "lru",
+			policy:  EvictionLRU,
+			access:  []int64{0, 1, 0, 2},
+			resided: 0,
+			evicted: 1,
+		},
+		{
+			name:    "clock",
+			policy:  EvictionClock,
+			access:  []int64{0, 1, 2},
+			resided: 1,
+			evicted: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			file := newPagedMemFile(16, 4)
+			bp, _ := NewBufferPool(16, 2, tt.policy)
+			for _, pageNo := range tt.access {
+				page, err := bp.Pin(file, pageNo)
+				if err != ErrOk {
+					t.Fatalf("got err = %v, want = %v", err, ErrOk)
+				}
+				bp.Unpin(page, false)
+			}
+			if _, ok := bp.table[ionPageID{file, tt.resided}]; !ok {
+				t.Errorf("got page %v evicted, want resident", tt.resided)
+			}
+			if _, ok := bp.table[ionPageID{file, tt.evicted}]; ok {
+				t.Errorf("got page %v resident, want evicted", tt.evicted)
+			}
+			if bp.Stats().Evictions != 1 {
+				t.Errorf("got evictions = %v, want = %v", bp.Stats().Evictions, 1)
+			}
+		})
+	}
+}
+
+func TestBufferPoolPinned(t *testing.T) {
+	file := newPagedMemFile(16, 4)
+	bp, _ := NewBufferPool(16, 1, EvictionLRU)
+
+	t.Run("pinned page is not evicted", func(t *testing.T) {
+		page, err := bp.Pin(file, 0)
+		if err != ErrOk {
+			t.Fatalf("got err = %v, want = %v", err, ErrOk)
+		}
+		if _, err := bp.Pin(file, 1); err != ErrOutOfMemory {
+			t.Errorf("got err = %v, want = %v", err, ErrOutOfMemory)
+		}
+		bp.Unpin(page, false)
+		page, err = bp.Pin(file, 1)
+		if err != ErrOk {
+			t.Errorf("got err = %v, want = %v", err, ErrOk)
+		}
+		bp.Unpin(page, false)
+	})
+}
+
+func TestBufferPoolWriteBack(t *testing.T) {
+	file := newPagedMemFile(16, 2)
+	bp, _ := NewBufferPool(16, 1, EvictionLRU)
+
+	t.Run("dirty page written on eviction", func(t *testing.T) {
+		page, _ := bp.Pin(file, 0)
+		page.Data[1] = 42
+		bp.Unpin(page, true)
+		if file.data[1] != 0 {
+			t.Errorf("got file byte = %v before eviction, want = %v", file.data[1], 0)
+		}
+		page, _ = bp.Pin(file, 1)
+		bp.Unpin(page, false)
+		if file.data[1] != 42 {
+			t.Errorf("got file byte = %v, want = %v", file.data[1], 42)
+		}
+	})
+
+	t.Run("dirty page written on flush", func(t *testing.T) {
+		page, _ := bp.Pin(file, 3)
+		page.Data[0] = 7
+		bp.Unpin(page, true)
+		if err := bp.Flush(file); err != ErrOk {
+			t.Errorf("got err = %v, want = %v", err, ErrOk)
+		}
+		if file.data[3*16] != 7 {
+			t.Errorf("got file byte = %v, want = %v", file.data[3*16], 7)
+		}
+		if bp.Stats().WriteBacks != 2 {
+			t.Errorf("got writeBacks = %v, want = %v", bp.Stats().WriteBacks, 2)
+		}
+	})
+}
+
+func TestBufferPoolChecksums(t *testing.T) {
+	// at is where page pageNo starts, past the header.
+	at := func(pageNo int) int { return (pageNo + 1) * 16 }
+	// four written pages of 16 bytes, 12 of them data.
+	written := func() *memFile {
+		file := new(memFile)
+		bp, _ := NewBufferPool(16, 4, EvictionLRU)
+		bp.EnableChecksums()
+		for pageNo := int64(0); pageNo < 4; pageNo++ {
+			page, _ := bp.Pin(file, pageNo)
+			for i := range page.Data {
+				page.Data[i] = byte(pageNo + 1)
+			}
+			bp.Unpin(page, true)
+		}
+		bp.Flush(file)
+		return file
+	}
+
+	tests := []struct {
+		name    string
+		corrupt func(file *memFile)
+		verify  IonErr
+		bad     []int64
+	}{
+		{name: "intact", corrupt: func(file *memFile) {}},
+		{name: "flipped bit", corrupt: func(file *memFile) { file.data[at(1)+3] ^= 0x10 }, bad: []int64{1}},
+		{name: "bad trailer", corrupt: func(file *memFile) { file.data[at(3)+15]++ }, bad: []int64{3}},
+		{name: "torn last page", corrupt: func(file *memFile) { file.data = file.data[:at(3)+5] }, bad: []int64{3}},
+		{name: "truncated", corrupt: func(file *memFile) { file.data = file.data[:at(2)] }, bad: []int64{2, 3}},
+		{name: "zeroed page", corrupt: func(file *memFile) {
+			for i := at(2); i < at(3); i++ {
+				file.data[i] = 0
+			}
+		}, bad: []int64{2}},
+		{name: "two pages", corrupt: func(file *memFile) {
+			file.data[at(0)] = 0
+			file.data[at(2)] = 9
+		}, bad: []int64{0, 2}},
+		{name: "bad header", corrupt: func(file *memFile) { file.data[3]++ }, verify: ErrCorruptedData},
+		{name: "zeroed header", corrupt: func(file *memFile) {
+			for i := 0; i < at(0); i++ {
+				file.data[i] = 0
+			}
+		}, verify: ErrCorruptedData},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			file := written()
+			tt.corrupt(file)
+			bp, _ := NewBufferPool(16, 2, EvictionLRU)
+			bp.EnableChecksums()
+			if got := bp.PageSize(); got != 12 {
+				t.Errorf("got page size = %v, want = %v", got, 12)
+			}
+
+			bad, err := bp.Verify(file)
+			if err != tt.verify {
+				t.Fatalf("got err = %v, want = %v", err, tt.verify)
+			}
+			if tt.verify != ErrOk {
+				if _, err := bp.Pin(file, 0); err != tt.verify {
+					t.Errorf("got pin err = %v, want = %v", err, tt.verify)
+				}
+				return
+			}
+			if len(bad) != len(tt.bad) {
+				t.Fatalf("got bad pages = %v, want = %v", bad, tt.bad)
+			}
+			for i := range bad {
+				if bad[i] != tt.bad[i] {
+					t.Errorf("got bad pages = %v, want = %v", bad, tt.bad)
+				}
+			}
+
+			isBad := make(map[int64]bool)
+			for _, pageNo := range tt.bad {
+				isBad[pageNo] = true
+			}
+			for pageNo := int64(0); pageNo < 4; pageNo++ {
+				page, err := bp.Pin(file, pageNo)
+				if isBad[pageNo] {
+					if err != ErrCorruptedData {
+						t.Errorf("got err = %v for page %v, want = %v", err, pageNo, ErrCorruptedData)
+					}
+					continue
+				}
+				if err != ErrOk {
+					t.Fatalf("got err = %v for page %v, want = %v", err, pageNo, ErrOk)
+				}
+				if len(page.Data) != 12 {
+					t.Errorf("got page length = %v, want = %v", len(page.Data), 12)
+				}
+				bp.Unpin(page, false)
+			}
+			if got := bp.Stats().Corruptions; got != len(tt.bad) {
+				t.Errorf("got corruptions = %v, want = %v", got, len(tt.bad))
+			}
+		})
+	}
+
+	t.Run("sparse", func(t *testing.T) {
+		file := new(memFile)
+		bp, _ := NewBufferPool(16, 2, EvictionLRU)
+		bp.EnableChecksums()
+		page, _ := bp.Pin(file, 3)
+		page.Data[0] = 3
+		bp.Unpin(page, true)
+		bp.Flush(file)
+		if bad, err := bp.Verify(file); err != ErrOk || len(bad) != 0 {
+			t.Errorf("got verify = %v, %v, want no bad pages", bad, err)
+		}
+		page, err := bp.Pin(file, 1)
+		if err != ErrOk || page.Data[0] != 0 {
+			t.Errorf("got page 1 = %v, %v, want zeros", page, err)
+		}
+	})
+
+	t.Run("page too small", func(t *testing.T) {
+		for _, size := range []int{2, 4, 11} {
+			bp, _ := NewBufferPool(size, 2, EvictionLRU)
+			if err := bp.EnableChecksums(); err != ErrInvalidiInitialSize {
+				t.Errorf("got err = %v for %v byte pages, want = %v", err, size, ErrInvalidiInitialSize)
+			}
+			if _, err := bp.Pin(new(memFile), 0); err != ErrOk {
+				t.Errorf("got pin err = %v for %v byte pages, want = %v", err, size, ErrOk)
+			}
+		}
+		bp, _ := NewBufferPool(12, 2, EvictionLRU)
+		if err := bp.EnableChecksums(); err != ErrOk {
+			t.Errorf("got err = %v for 12 byte pages, want = %v", err, ErrOk)
+		}
+	})
+
+	t.Run("verify needs checksums", func(t *testing.T) {
+		bp, _ := NewBufferPool(16, 2, EvictionLRU)
+		if _, err := bp.Verify(written()); err != ErrNotImplemented {
+			t.Errorf("got err = %v, want = %v", err, ErrNotImplemented)
+		}
+	})
+}
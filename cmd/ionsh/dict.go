@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/gettsu/iondb"
+)
+
+// shellDict is a dictionary the shell can query with keys and values
+// written as text, whatever its key and value types are.
+type shellDict interface {
+	describe() (keyType string, valType string)
+	put(key, val string) error
+	get(key string) ([][2]string, error)
+	between(lo, hi string) ([][2]string, error)
+	prefix(prefix string) ([][2]string, error)
+	count() int
+	remove(key string) (int, error)
+}
+
+// typedDict parses and prints the keys and values of a Dictionary[K, V].
+type typedDict[K, V any] struct {
+	dict      iondb.Dictionary[K, V]
+	kType     iondb.IonKeyType
+	kName     string
+	vName     string
+	parseKey  func(text string) (K, error)
+	parseVal  func(text string) (V, error)
+	formatKey func(key K) string
+	formatVal func(val V) string
+}
+
+// shellKeyTypes are the key types the shell can open dictionaries with.
+var shellKeyTypes = []string{"int", "uint", "string"}
+
+// shellValTypes are the value types the shell can open dictionaries with.
+var shellValTypes = []string{"int", "uint", "float", "string"}
+
+// newShellDict creates an in-memory dictionary with the named key and value
+// types.
+func newShellDict(id iondb.IonDictionaryID, kName, vName string, dictSize iondb.IonDictionarySize) (shellDict, error) {
+	switch kName {
+	case "int":
+		return withValType(id, kName, vName, dictSize, iondb.KeyTypeNumericSigned, parseInt, formatInt)
+	case "uint":
+		return withValType(id, kName, vName, dictSize, iondb.KeyTypeNumericUnsigned, parseUint, formatUint)
+	case "string":
+		return withValType(id, kName, vName, dictSize, iondb.KeyTypeNullTerminatedString, parseString, strconv.Quote)
+	}
+	return nil, fmt.Errorf("unknown key type %q, want one of %v", kName, shellKeyTypes)
+}
+
+func withValType[K any](id iondb.IonDictionaryID, kName, vName string, dictSize iondb.IonDictionarySize, kType iondb.IonKeyType, parseKey func(string) (K, error), formatKey func(K) string) (shellDict, error) {
+	switch vName {
+	case "int":
+		return newTypedDict(id, kName, vName, dictSize, kType, parseKey, formatKey, parseInt, formatInt), nil
+	case "uint":
+		return newTypedDict(id, kName, vName, dictSize, kType, parseKey, formatKey, parseUint, formatUint), nil
+	case "float":
+		return newTypedDict(id, kName, vName, dictSize, kType, parseKey, formatKey, parseFloat, formatFloat), nil
+	case "string":
+		return newTypedDict(id, kName, vName, dictSize, kType, parseKey, formatKey, parseString, strconv.Quote), nil
+	}
+	return nil, fmt.Errorf("unknown value type %q, want one of %v", vName, shellValTypes)
+}
+
+func newTypedDict[K, V any](id iondb.IonDictionaryID, kName, vName string, dictSize iondb.IonDictionarySize, kType iondb.IonKeyType, parseKey func(string) (K, error), formatKey func(K) string, parseVal func(string) (V, error), formatVal func(V) string) *typedDict[K, V] {
+	var key K
+	var val V
+	return &typedDict[K, V]{
+		dict:      iondb.NewSkipList[K, V](id, kType, int(unsafe.Sizeof(key)), uint(unsafe.Sizeof(val)), dictSize),
+		kType:     kType,
+		kName:     kName,
+		vName:     vName,
+		parseKey:  parseKey,
+		parseVal:  parseVal,
+		formatKey: formatKey,
+		formatVal: formatVal,
+	}
+}
+
+func (td *typedDict[K, V]) describe() (string, string) {
+	return td.kName, td.vName
+}
+
+func (td *typedDict[K, V]) put(key, val string) error {
+	k, err := td.parseKey(key)
+	if err != nil {
+		return err
+	}
+	v, err := td.parseVal(val)
+	if err != nil {
+		return err
+	}
+	return statusErr(td.dict.Insert(k, v).Err)
+}
+
+func (td *typedDict[K, V]) get(key string) ([][2]string, error) {
+	k, err := td.parseKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return td.rows(td.dict.Equality(k), nil), nil
+}
+
+func (td *typedDict[K, V]) between(lo, hi string) ([][2]string, error) {
+	minKey, err := td.parseKey(lo)
+	if err != nil {
+		return nil, err
+	}
+	maxKey, err := td.parseKey(hi)
+	if err != nil {
+		return nil, err
+	}
+	return td.rows(td.dict.Range(minKey, maxKey), nil), nil
+}
+
+// prefix finds the string keys starting with prefix by scanning from the
+// prefix up to the smallest string after every key that starts with it.
+func (td *typedDict[K, V]) prefix(text string) ([][2]string, error) {
+	if td.kType != iondb.KeyTypeNullTerminatedString {
+		return nil, fmt.Errorf("prefix needs string keys")
+	}
+	prefix, err := parseString(text)
+	if err != nil {
+		return nil, err
+	}
+	match := func(key K) bool {
+		return strings.HasPrefix(*(*string)(unsafe.Pointer(&key)), prefix)
+	}
+	upper := []byte(prefix)
+	for len(upper) > 0 && upper[len(upper)-1] == 0xff {
+		upper = upper[:len(upper)-1]
+	}
+	if len(upper) == 0 {
+		return td.rows(td.dict.AllRecords(), match), nil
+	}
+	upper[len(upper)-1]++
+	lo, hi := prefix, string(upper)
+	return td.rows(td.dict.Range(*(*K)(unsafe.Pointer(&lo)), *(*K)(unsafe.Pointer(&hi))), match), nil
+}
+
+func (td *typedDict[K, V]) count() int {
+	return iondb.Count(td.dict.AllRecords())
+}
+
+func (td *typedDict[K, V]) remove(key string) (int, error) {
+	k, err := td.parseKey(key)
+	if err != nil {
+		return 0, err
+	}
+	status := td.dict.DeleteRecord(k)
+	return int(status.ResCnt), statusErr(status.Err)
+}
+
+// rows reads the records of cursor that match, or all of them if match is
+// nil.
+func (td *typedDict[K, V]) rows(cursor *iondb.Cursor[K, V], match func(key K) bool) [][2]string {
+	var rows [][2]string
+	for cursor.Next(); cursor.HasNext(); cursor.Next() {
+		if match != nil && !match(cursor.GetKey()) {
+			continue
+		}
+		rows = append(rows, [2]string{td.formatKey(cursor.GetKey()), td.formatVal(cursor.GetValue())})
+	}
+	return rows
+}
+
+func parseInt(text string) (int64, error) {
+	return strconv.ParseInt(text, 0, 64)
+}
+
+func formatInt(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+func parseUint(text string) (uint64, error) {
+	return strconv.ParseUint(text, 0, 64)
+}
+
+func formatUint(v uint64) string {
+	return strconv.FormatUint(v, 10)
+}
+
+func parseFloat(text string) (float64, error) {
+	return strconv.ParseFloat(text, 64)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// parseString takes text as it is, or unquotes it if it is quoted.
+func parseString(text string) (string, error) {
+	if strings.HasPrefix(text, `"`) {
+		return strconv.Unquote(text)
+	}
+	return text, nil
+}
+
+func statusErr(err iondb.IonErr) error {
+	switch err {
+	case iondb.ErrOk:
+		return nil
+	case iondb.ErrItemNotFound:
+		return fmt.Errorf("item not found")
+	case iondb.ErrDuplicateKey:
+		return fmt.Errorf("duplicate key")
+	case iondb.ErrMaxCapacity:
+		return fmt.Errorf("dictionary is full")
+	}
+	return fmt.Errorf("iondb error %d", err)
+}
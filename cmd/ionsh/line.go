@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// lineEditor reads lines from a terminal in raw mode, where every key
+// arrives as it is pressed, so tab can complete the line in place.
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	complete func(line string) []string
+}
+
+// readLine shows prompt and returns the line once enter is pressed. It
+// returns false at the end of the input or on ctrl-D on an empty line.
+func (le *lineEditor) readLine(prompt string) (string, bool) {
+	fmt.Fprint(le.out, prompt)
+	var line []byte
+	for {
+		c, err := le.in.ReadByte()
+		if err != nil {
+			return string(line), len(line) > 0
+		}
+		switch {
+		case c == '\r' || c == '\n':
+			fmt.Fprint(le.out, "\n")
+			return string(line), true
+		case c == 0x04:
+			if len(line) == 0 {
+				fmt.Fprint(le.out, "\n")
+				return "", false
+			}
+		case c == 0x03:
+			fmt.Fprint(le.out, "^C\n"+prompt)
+			line = line[:0]
+		case c == 0x7f || c == '\b':
+			if len(line) > 0 {
+				_, size := utf8.DecodeLastRune(line)
+				line = line[:len(line)-size]
+				fmt.Fprint(le.out, "\b \b")
+			}
+		case c == '\t':
+			line = le.tab(prompt, line)
+		case c == 0x1b:
+			le.skipEscape()
+		case c >= 0x20:
+			line = append(line, c)
+			le.out.Write([]byte{c})
+		}
+	}
+}
+
+// tab completes the last word of line as far as every candidate agrees,
+// with a space after it if there is only one. With nothing to add it lists
+// the candidates and shows the line again below them.
+func (le *lineEditor) tab(prompt string, line []byte) []byte {
+	matches := le.complete(string(line))
+	if len(matches) == 0 {
+		return line
+	}
+	word := string(line[strings.LastIndexAny(string(line), " \t")+1:])
+	common := matches[0]
+	for _, match := range matches[1:] {
+		for !strings.HasPrefix(match, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if len(matches) == 1 {
+		common += " "
+	}
+	if len(common) > len(word) {
+		rest := common[len(word):]
+		fmt.Fprint(le.out, rest)
+		return append(line, rest...)
+	}
+	fmt.Fprintf(le.out, "\n%s\n%s%s", strings.Join(matches, "  "), prompt, line)
+	return line
+}
+
+// skipEscape drops the rest of an escape sequence, such as an arrow key.
+func (le *lineEditor) skipEscape() {
+	c, err := le.in.ReadByte()
+	if err != nil || (c != '[' && c != 'O') {
+		return
+	}
+	for {
+		c, err = le.in.ReadByte()
+		if err != nil || (c >= 0x40 && c <= 0x7e) {
+			return
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Command ionsh is an interactive shell for ad-hoc queries on iondb
+// dictionaries.
+//
+// Every -dict flag opens an in-memory dictionary as id:key:value, with key
+// one of int, uint or string and value one of int, uint, float or string:
+//
+//	ionsh -dict 1:string:int -dict 2:int:float
+//
+// Keys and values are parsed by the key type of the dictionary they go to.
+// Strings with spaces are written in double quotes. On a linux terminal tab
+// completes commands and dictionary IDs. The complete command lists what
+// the rest of its line can be completed with.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gettsu/iondb"
+)
+
+type dictFlags []string
+
+func (df *dictFlags) String() string {
+	return strings.Join(*df, ",")
+}
+
+func (df *dictFlags) Set(value string) error {
+	*df = append(*df, value)
+	return nil
+}
+
+// openDicts creates a dictionary for every id:key:value spec.
+func openDicts(specs []string, dictSize iondb.IonDictionarySize) (map[iondb.IonDictionaryID]shellDict, error) {
+	dicts := make(map[iondb.IonDictionaryID]shellDict)
+	for _, spec := range specs {
+		parts := strings.Split(spec, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("dictionary %q is not id:key:value", spec)
+		}
+		id, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("dictionary %q: %v", spec, err)
+		}
+		if _, ok := dicts[id]; ok {
+			return nil, fmt.Errorf("dictionary %d is given twice", id)
+		}
+		dict, err := newShellDict(id, parts[1], parts[2], dictSize)
+		if err != nil {
+			return nil, fmt.Errorf("dictionary %q: %v", spec, err)
+		}
+		dicts[id] = dict
+	}
+	return dicts, nil
+}
+
+func main() {
+	var specs dictFlags
+	flag.Var(&specs, "dict", "open an in-memory dictionary `id:key:value`, may be repeated")
+	dictSize := flag.Uint("size", 7, "skip list height of the dictionaries")
+	flag.Parse()
+
+	if len(specs) == 0 {
+		specs = append(specs, "1:string:string")
+	}
+	dicts, err := openDicts(specs, iondb.IonDictionarySize(*dictSize))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ionsh:", err)
+		os.Exit(2)
+	}
+
+	sh := newShell(dicts, os.Stdout)
+	interactive := false
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		interactive = true
+	}
+	if interactive {
+		if restore, err := rawTerminal(os.Stdin); err == nil {
+			defer restore()
+			sh.edit(os.Stdin)
+			return
+		}
+	}
+	sh.run(os.Stdin, interactive)
+}
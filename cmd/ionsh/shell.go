@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gettsu/iondb"
+)
+
+// shell runs commands against a set of open dictionaries, one of them
+// selected at a time.
+type shell struct {
+	dicts   map[iondb.IonDictionaryID]shellDict
+	current iondb.IonDictionaryID
+	out     io.Writer
+}
+
+type shellCommand struct {
+	usage string
+	args  int
+	// raw commands get the rest of the line, unsplit, as their one argument.
+	raw bool
+	run func(sh *shell, dict shellDict, args []string) error
+}
+
+var shellCommands map[string]shellCommand
+
+func init() {
+	shellCommands = map[string]shellCommand{
+		"help":   {usage: "help", run: (*shell).help},
+		"dicts":  {usage: "dicts", run: (*shell).list},
+		"use":    {usage: "use <id>", args: 1, run: (*shell).use},
+		"put":    {usage: "put <key> <value>", args: 2, run: (*shell).put},
+		"get":    {usage: "get <key>", args: 1, run: (*shell).get},
+		"range":  {usage: "range <from> <to>", args: 2, run: (*shell).between},
+		"prefix": {usage: "prefix <prefix>", args: 1, run: (*shell).prefix},
+		"count":  {usage: "count [<from> <to>]", run: (*shell).count},
+		"delete": {usage: "delete <key>", args: 1, run: (*shell).remove},
+		// for scripts and terminals the shell can't put in raw mode, where
+		// tab does nothing.
+		"complete": {usage: "complete [<line>]", raw: true, run: (*shell).completions},
+	}
+}
+
+func newShell(dicts map[iondb.IonDictionaryID]shellDict, out io.Writer) *shell {
+	sh := &shell{dicts: dicts, out: out}
+	ids := sh.ids()
+	if len(ids) > 0 {
+		sh.current = ids[0]
+	}
+	return sh
+}
+
+func (sh *shell) ids() []iondb.IonDictionaryID {
+	ids := make([]iondb.IonDictionaryID, 0, len(sh.dicts))
+	for id := range sh.dicts {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (sh *shell) prompt() string {
+	return "iondb[" + strconv.Itoa(sh.current) + "]> "
+}
+
+// run reads commands from in until it ends or a quit command.
+func (sh *shell) run(in io.Reader, interactive bool) {
+	scanner := bufio.NewScanner(in)
+	sh.loop(func() (string, bool) {
+		if interactive {
+			fmt.Fprint(sh.out, sh.prompt())
+		}
+		if !scanner.Scan() {
+			return "", false
+		}
+		return scanner.Text(), true
+	})
+}
+
+// edit reads commands like run from a terminal in raw mode, completing the
+// line when tab is pressed.
+func (sh *shell) edit(in io.Reader) {
+	le := &lineEditor{in: bufio.NewReader(in), out: sh.out, complete: sh.complete}
+	sh.loop(func() (string, bool) {
+		return le.readLine(sh.prompt())
+	})
+}
+
+func (sh *shell) loop(next func() (string, bool)) {
+	for {
+		line, ok := next()
+		if !ok {
+			return
+		}
+		if fields, _ := shellFields(line); len(fields) > 0 && (fields[0] == "quit" || fields[0] == "exit") {
+			return
+		}
+		if err := sh.execute(line); err != nil {
+			fmt.Fprintln(sh.out, "error:", err)
+		}
+	}
+}
+
+func (sh *shell) execute(line string) error {
+	fields, err := shellFields(line)
+	if err != nil || len(fields) == 0 {
+		return err
+	}
+	cmd, ok := shellCommands[fields[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q, try help", fields[0])
+	}
+	args := fields[1:]
+	if cmd.raw {
+		rest := strings.TrimPrefix(strings.TrimLeft(line, " \t"), fields[0])
+		args = []string{strings.TrimLeft(rest, " \t")}
+	}
+	if cmd.args > 0 && len(args) != cmd.args {
+		return fmt.Errorf("usage: %s", cmd.usage)
+	}
+	return cmd.run(sh, sh.dicts[sh.current], args)
+}
+
+// complete returns the command names that line can start with, or the
+// dictionary IDs after use.
+func (sh *shell) complete(line string) []string {
+	fields, _ := shellFields(line)
+	var candidates []string
+	var word string
+	switch {
+	case len(fields) == 0 || (len(fields) == 1 && !strings.HasSuffix(line, " ")):
+		if len(fields) == 1 {
+			word = fields[0]
+		}
+		for name := range shellCommands {
+			candidates = append(candidates, name)
+		}
+		candidates = append(candidates, "quit")
+		sort.Strings(candidates)
+	case fields[0] == "use" && (len(fields) == 1 || (len(fields) == 2 && !strings.HasSuffix(line, " "))):
+		if len(fields) == 2 {
+			word = fields[1]
+		}
+		for _, id := range sh.ids() {
+			candidates = append(candidates, strconv.Itoa(id))
+		}
+	}
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) {
+			matches = append(matches, candidate)
+		}
+	}
+	return matches
+}
+
+// completions prints what the line in args can be completed with.
+func (sh *shell) completions(_ shellDict, args []string) error {
+	fmt.Fprintln(sh.out, strings.Join(sh.complete(args[0]), "  "))
+	return nil
+}
+
+func (sh *shell) help(_ shellDict, _ []string) error {
+	names := make([]string, 0, len(shellCommands))
+	for name := range shellCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintln(sh.out, "  "+shellCommands[name].usage)
+	}
+	fmt.Fprintln(sh.out, "  quit")
+	return nil
+}
+
+func (sh *shell) list(_ shellDict, _ []string) error {
+	rows := make([][]string, 0, len(sh.dicts))
+	for _, id := range sh.ids() {
+		kName, vName := sh.dicts[id].describe()
+		rows = append(rows, []string{strconv.Itoa(id), kName, vName, strconv.Itoa(sh.dicts[id].count())})
+	}
+	sh.table([]string{"id", "key", "value", "records"}, rows)
+	return nil
+}
+
+func (sh *shell) use(_ shellDict, args []string) error {
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return err
+	}
+	if _, ok := sh.dicts[id]; !ok {
+		return fmt.Errorf("no dictionary %d", id)
+	}
+	sh.current = id
+	return nil
+}
+
+func (sh *shell) put(dict shellDict, args []string) error {
+	if dict == nil {
+		return fmt.Errorf("no dictionary is open")
+	}
+	return dict.put(args[0], args[1])
+}
+
+func (sh *shell) get(dict shellDict, args []string) error {
+	if dict == nil {
+		return fmt.Errorf("no dictionary is open")
+	}
+	return sh.records(dict.get(args[0]))
+}
+
+func (sh *shell) between(dict shellDict, args []string) error {
+	if dict == nil {
+		return fmt.Errorf("no dictionary is open")
+	}
+	return sh.records(dict.between(args[0], args[1]))
+}
+
+func (sh *shell) prefix(dict shellDict, args []string) error {
+	if dict == nil {
+		return fmt.Errorf("no dictionary is open")
+	}
+	return sh.records(dict.prefix(args[0]))
+}
+
+func (sh *shell) count(dict shellDict, args []string) error {
+	if dict == nil {
+		return fmt.Errorf("no dictionary is open")
+	}
+	switch len(args) {
+	case 0:
+		fmt.Fprintln(sh.out, dict.count())
+	case 2:
+		rows, err := dict.between(args[0], args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(sh.out, len(rows))
+	default:
+		return fmt.Errorf("usage: %s", shellCommands["count"].usage)
+	}
+	return nil
+}
+
+func (sh *shell) remove(dict shellDict, args []string) error {
+	if dict == nil {
+		return fmt.Errorf("no dictionary is open")
+	}
+	removed, err := dict.remove(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(sh.out, "deleted %d\n", removed)
+	return nil
+}
+
+func (sh *shell) records(rows [][2]string, err error) error {
+	if err != nil {
+		return err
+	}
+	table := make([][]string, len(rows))
+	for i := range rows {
+		table[i] = rows[i][:]
+	}
+	sh.table([]string{"key", "value"}, table)
+	return nil
+}
+
+// table prints rows in aligned columns under a header and a rule, followed
+// by the row count.
+func (sh *shell) table(header []string, rows [][]string) {
+	tw := tabwriter.NewWriter(sh.out, 0, 0, 2, ' ', 0)
+	rule := make([]string, len(header))
+	for i, name := range header {
+		rule[i] = strings.Repeat("-", len(name))
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	fmt.Fprintln(tw, strings.Join(rule, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	tw.Flush()
+	if len(rows) == 1 {
+		fmt.Fprintln(sh.out, "(1 row)")
+	} else {
+		fmt.Fprintf(sh.out, "(%d rows)\n", len(rows))
+	}
+}
+
+// shellFields splits line at spaces, keeping double quoted words with their
+// quotes so string keys can hold spaces.
+func shellFields(line string) ([]string, error) {
+	var fields []string
+	line = strings.TrimLeft(line, " \t")
+	for len(line) > 0 {
+		end := strings.IndexAny(line, " \t")
+		if line[0] == '"' {
+			quoted, err := strconv.QuotedPrefix(line)
+			if err != nil {
+				return nil, fmt.Errorf("unterminated string in %q", line)
+			}
+			end = len(quoted)
+		}
+		if end < 0 {
+			end = len(line)
+		}
+		fields = append(fields, line[:end])
+		line = strings.TrimLeft(line[end:], " \t")
+	}
+	return fields, nil
+}
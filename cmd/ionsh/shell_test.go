@@ -0,0 +1,167 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestShell(t *testing.T) {
+	tests := []struct {
+		name   string
+		specs  []string
+		script string
+		want   string
+	}{
+		{
+			name:   "put and get",
+			specs:  []string{"1:string:int"},
+			script: "put apple 3\nput \"big apple\" 4\nput apple 5\nget apple\n",
+			want:   "key      value\n---      -----\n\"apple\"  3\n\"apple\"  5\n(2 rows)\n",
+		},
+		{
+			name:   "range and count",
+			specs:  []string{"1:int:float"},
+			script: "put 1 0.5\nput -2 1.5\nput 9 2\nrange -5 1\ncount\ncount 0 100\n",
+			want:   "key  value\n---  -----\n-2   1.5\n1    0.5\n(2 rows)\n3\n2\n",
+		},
+		{
+			name:   "prefix",
+			specs:  []string{"1:string:string"},
+			script: "put ab x\nput abc y\nput abd z\nput ac w\nput \"ab\\xff\" v\nprefix abc\nprefix ab\n",
+			want: "key    value\n---    -----\n\"abc\"  \"y\"\n(1 row)\n" +
+				"key       value\n---       -----\n\"ab\"      \"x\"\n\"abc\"     \"y\"\n\"abd\"     \"z\"\n\"ab\\xff\"  \"v\"\n(4 rows)\n",
+		},
+		{
+			name:   "delete",
+			specs:  []string{"1:uint:int"},
+			script: "put 4 1\nput 4 2\ndelete 4\ndelete 4\ncount\n",
+			want:   "deleted 2\nerror: item not found\n0\n",
+		},
+		{
+			name:   "switch dictionaries",
+			specs:  []string{"1:int:int", "2:string:uint"},
+			script: "put 1 1\nuse 2\nput a 7\nuse 3\ndicts\n",
+			want:   "error: no dictionary 3\nid  key     value  records\n--  ---     -----  -------\n1   int     int    1\n2   string  uint   1\n(2 rows)\n",
+		},
+		{
+			name:   "bad input",
+			specs:  []string{"1:int:int"},
+			script: "put x 1\nput 1\nprefix a\nfrobnicate\nget \"1\n",
+			want: "error: strconv.ParseInt: parsing \"x\": invalid syntax\nerror: usage: put <key> <value>\n" +
+				"error: prefix needs string keys\nerror: unknown command \"frobnicate\", try help\nerror: unterminated string in \"\\\"1\"\n",
+		},
+		{
+			name:   "complete",
+			specs:  []string{"1:int:int", "2:int:int"},
+			script: "complete p\ncomplete use \ncomplete\t use\ncomplete get 1\n",
+			want:   "prefix  put\n1  2\nuse\n\n",
+		},
+		{
+			name:   "quit",
+			specs:  []string{"1:int:int"},
+			script: "put 1 1\nquit\nput 2 2\n",
+			want:   "",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dicts, err := openDicts(tt.specs, 7)
+			if err != nil {
+				t.Fatalf("got err = %v, want = %v", err, nil)
+			}
+			var out strings.Builder
+			newShell(dicts, &out).run(strings.NewReader(tt.script), false)
+			if got := out.String(); got != tt.want {
+				t.Errorf("got output = %q, want = %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShellComplete(t *testing.T) {
+	dicts, err := openDicts([]string{"1:int:int", "12:int:int", "2:int:int"}, 7)
+	if err != nil {
+		t.Fatalf("got err = %v, want = %v", err, nil)
+	}
+	sh := newShell(dicts, &strings.Builder{})
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{line: "p", want: []string{"prefix", "put"}},
+		{line: "de", want: []string{"delete"}},
+		{line: "c", want: []string{"complete", "count"}},
+		{line: "use ", want: []string{"1", "2", "12"}},
+		{line: "use 1", want: []string{"1", "12"}},
+		{line: "get ", want: nil},
+	}
+	for _, tt := range tests {
+		if got := sh.complete(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("got complete(%q) = %v, want = %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestShellLineEditor(t *testing.T) {
+	tests := []struct {
+		name string
+		keys string
+		want string
+	}{
+		{
+			name: "complete a command",
+			keys: "us\t12\n",
+			want: "iondb[1]> use 12\niondb[12]> ",
+		},
+		{
+			name: "list candidates",
+			keys: "use \t\x03",
+			want: "iondb[1]> use \n1  2  12\niondb[1]> use ^C\niondb[1]> ",
+		},
+		{
+			name: "common prefix",
+			keys: "use 1\t\x7f2\n",
+			want: "iondb[1]> use 1\n1  12\niondb[1]> use 1\b \b2\niondb[2]> ",
+		},
+		{
+			name: "skip escapes and end on ctrl-D",
+			keys: "\x1b[Aget 5\n\x04put 1 1\n",
+			want: "iondb[1]> get 5\nkey  value\n---  -----\n(0 rows)\niondb[1]> \n",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dicts, err := openDicts([]string{"1:int:int", "12:int:int", "2:int:int"}, 7)
+			if err != nil {
+				t.Fatalf("got err = %v, want = %v", err, nil)
+			}
+			var out strings.Builder
+			newShell(dicts, &out).edit(strings.NewReader(tt.keys))
+			if got := out.String(); got != tt.want {
+				t.Errorf("got output = %q, want = %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenDicts(t *testing.T) {
+	tests := []struct {
+		specs []string
+		ok    bool
+	}{
+		{specs: []string{"1:int:string", "2:uint:float"}, ok: true},
+		{specs: []string{"1:int"}},
+		{specs: []string{"x:int:int"}},
+		{specs: []string{"1:bytes:int"}},
+		{specs: []string{"1:int:bool"}},
+		{specs: []string{"1:int:int", "1:string:int"}},
+	}
+	for _, tt := range tests {
+		if _, err := openDicts(tt.specs, 7); (err == nil) != tt.ok {
+			t.Errorf("got openDicts(%v) err = %v, want ok = %v", tt.specs, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// rawTerminal turns off line buffering, echo and signal keys on the
+// terminal f, so the shell sees every key, and returns how to turn them
+// back on.
+func rawTerminal(f *os.File) (func(), error) {
+	fd := f.Fd()
+	var old syscall.Termios
+	if err := termios(fd, syscall.TCGETS, &old); err != nil {
+		return nil, err
+	}
+	raw := old
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO | syscall.ISIG | syscall.IEXTEN
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := termios(fd, syscall.TCSETS, &raw); err != nil {
+		return nil, err
+	}
+	return func() { termios(fd, syscall.TCSETS, &old) }, nil
+}
+
+func termios(fd uintptr, request uintptr, t *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(unsafe.Pointer(t))); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// rawTerminal is only implemented on linux, elsewhere the shell reads
+// whole lines and completion stays with the complete command.
+func rawTerminal(f *os.File) (func(), error) {
+	return nil, errors.New("raw terminal mode is not supported")
+}
@@ -0,0 +1,320 @@
+package iondb
+
+import (
+	"encoding/binary"
+	"reflect"
+	"unsafe"
+)
+
+// IonValueCodec compresses the values of a dictionary. Encode appends the
+// encoding of src to dst and returns it. Decode fills dst, which is as long
+// as the values of the dictionary, from an encoding made by Encode and
+// fails with ErrCorruptedData if src is not one.
+type IonValueCodec interface {
+	Encode(dst []byte, src []byte) []byte
+	Decode(dst []byte, src []byte) IonErr
+}
+
+// IonCompressionStats counts the values a dictionary holds compressed.
+type IonCompressionStats struct {
+	Values int
+	// RawBytes is what the values would take uncompressed.
+	RawBytes int
+	// StoredBytes is what they take, length prefixes included.
+	StoredBytes int
+}
+
+// WithValueCodec makes the dictionary store every value encoded by codec.
+// Values are encoded on insert and update and decoded on every read, so
+// callers only ever see them as they were written. The encoding hides any
+// pointer in a value from the garbage collector, so value types holding
+// pointers, strings, slices or interfaces are refused with
+// ErrUnableToConvert.
+func WithValueCodec(codec IonValueCodec) IonDictionaryOption {
+	return func(conf *IonDictionaryConfigInfo) {
+		conf.codec = codec
+	}
+}
+
+// valueHasPointers reports whether values of type V hold pointers.
+func valueHasPointers[V any]() bool {
+	return typeHasPointers(reflect.TypeOf((*V)(nil)).Elem())
+}
+
+// valueComparable reports whether values of type V can be compared with ==.
+func valueComparable[V any]() bool {
+	return reflect.TypeOf((*V)(nil)).Elem().Comparable()
+}
+
+func typeHasPointers(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return false
+	case reflect.Array:
+		return t.Len() > 0 && typeHasPointers(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if typeHasPointers(t.Field(i).Type) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// dictEncodeValue encodes the value at val as the stored form handlers
+// keep: the length of the encoding as a uvarint, then the encoding.
+func dictEncodeValue(parent *IonDictionaryParent, val IonValue) []byte {
+	raw := unsafe.Slice((*byte)(val), parent.record.valueSize)
+	enc := parent.codec.Encode(nil, raw)
+	stored := make([]byte, 0, binary.MaxVarintLen32+len(enc))
+	stored = binary.AppendUvarint(stored, uint64(len(enc)))
+	return append(stored, enc...)
+}
+
+// dictStoredValue returns the encoding of the stored value at stored and
+// the bytes it takes with its prefix.
+func dictStoredValue(stored IonValue) ([]byte, int) {
+	prefix := unsafe.Slice((*byte)(stored), binary.MaxVarintLen32)
+	size, n := binary.Uvarint(prefix)
+	if n <= 0 {
+		return nil, 0
+	}
+	return unsafe.Slice((*byte)(unsafe.Add(unsafe.Pointer(stored), n)), size), n + int(size)
+}
+
+// dictDecodeValue decodes the stored value at stored into val.
+func dictDecodeValue(parent *IonDictionaryParent, stored IonValue, val IonValue) IonErr {
+	enc, n := dictStoredValue(stored)
+	if n == 0 {
+		return ErrCorruptedData
+	}
+	return parent.codec.Decode(unsafe.Slice((*byte)(val), parent.record.valueSize), enc)
+}
+
+// NewLZCodec returns a codec in the style of an LZ4 block: runs of literal
+// bytes, each followed by a copy of at least four bytes from up to 64KiB
+// back. It suits values with repeated headers or padding.
+func NewLZCodec() IonValueCodec {
+	return ionLZCodec{}
+}
+
+type ionLZCodec struct{}
+
+const (
+	lzMinMatch  = 4
+	lzHashBits  = 10
+	lzMaxOffset = 1<<16 - 1
+	// the last bytes are always literals, so a match never runs to the end.
+	lzLastLiterals = 5
+)
+
+// lzAppendLength appends the part of a length that does not fit in its
+// four bits of the token: bytes of 255 and the remainder.
+func lzAppendLength(dst []byte, length int) []byte {
+	for length -= 15; length >= 255; length -= 255 {
+		dst = append(dst, 255)
+	}
+	return append(dst, byte(length))
+}
+
+func lzToken(literals int, match int) byte {
+	token := byte(0)
+	if literals >= 15 {
+		token = 15 << 4
+	} else {
+		token = byte(literals) << 4
+	}
+	if match >= 15 {
+		token |= 15
+	} else {
+		token |= byte(match)
+	}
+	return token
+}
+
+func (ionLZCodec) Encode(dst []byte, src []byte) []byte {
+	var table [1 << lzHashBits]int32
+	hash := func(i int) uint32 {
+		return binary.LittleEndian.Uint32(src[i:]) * 2654435761 >> (32 - lzHashBits)
+	}
+
+	anchor := 0
+	for i := 0; i+lzMinMatch+lzLastLiterals <= len(src); {
+		h := hash(i)
+		candidate := int(table[h]) - 1
+		table[h] = int32(i + 1)
+		if candidate < 0 || i-candidate > lzMaxOffset || binary.LittleEndian.Uint32(src[candidate:]) != binary.LittleEndian.Uint32(src[i:]) {
+			i++
+			continue
+		}
+		length := lzMinMatch
+		for i+length < len(src)-lzLastLiterals && src[candidate+length] == src[i+length] {
+			length++
+		}
+
+		literals := i - anchor
+		dst = append(dst, lzToken(literals, length-lzMinMatch))
+		if literals >= 15 {
+			dst = lzAppendLength(dst, literals)
+		}
+		dst = append(dst, src[anchor:i]...)
+		dst = binary.LittleEndian.AppendUint16(dst, uint16(i-candidate))
+		if length-lzMinMatch >= 15 {
+			dst = lzAppendLength(dst, length-lzMinMatch)
+		}
+		i += length
+		anchor = i
+	}
+
+	literals := len(src) - anchor
+	dst = append(dst, lzToken(literals, 0))
+	if literals >= 15 {
+		dst = lzAppendLength(dst, literals)
+	}
+	return append(dst, src[anchor:]...)
+}
+
+// lzReadLength adds the length bytes at src[i:] to a length whose four bits
+// in the token were all set.
+func lzReadLength(src []byte, i int, length int) (int, int, bool) {
+	if length < 15 {
+		return length, i, true
+	}
+	for {
+		if i >= len(src) {
+			return 0, i, false
+		}
+		b := src[i]
+		i++
+		length += int(b)
+		if b != 255 {
+			return length, i, true
+		}
+	}
+}
+
+func (ionLZCodec) Decode(dst []byte, src []byte) IonErr {
+	out := 0
+	for i := 0; i < len(src); {
+		token := src[i]
+		literals, next, ok := lzReadLength(src, i+1, int(token>>4))
+		i = next
+		if !ok || i+literals > len(src) || out+literals > len(dst) {
+			return ErrCorruptedData
+		}
+		out += copy(dst[out:], src[i:i+literals])
+		i += literals
+		if i == len(src) {
+			break
+		}
+
+		if i+2 > len(src) {
+			return ErrCorruptedData
+		}
+		offset := int(binary.LittleEndian.Uint16(src[i:]))
+		i += 2
+		length, next, ok := lzReadLength(src, i, int(token&15))
+		i = next
+		length += lzMinMatch
+		if !ok || offset == 0 || offset > out || out+length > len(dst) {
+			return ErrCorruptedData
+		}
+		// byte by byte, as a copy may overlap the bytes it writes.
+		for k := 0; k < length; k++ {
+			dst[out+k] = dst[out-offset+k]
+		}
+		out += length
+	}
+	if out != len(dst) {
+		return ErrCorruptedData
+	}
+	return ErrOk
+}
+
+// NewDeltaCodec returns a codec for values made of integers of width bytes,
+// 2, 4 or 8, in host byte order, like arrays of readings. It stores the
+// first integer and then the difference of each from the one before as
+// zigzag varints, so slowly changing numbers take a byte or two each. Bytes
+// past the last whole integer are stored as they are, and so is the whole
+// value for any other width.
+func NewDeltaCodec(width int) IonValueCodec {
+	if width != 2 && width != 4 && width != 8 {
+		width = 0
+	}
+	return ionDeltaCodec{width: width}
+}
+
+type ionDeltaCodec struct {
+	// 0 stores values as they are.
+	width int
+}
+
+// words is how many whole integers a value of size bytes holds.
+func (dc ionDeltaCodec) words(size int) int {
+	if dc.width == 0 {
+		return 0
+	}
+	return size / dc.width
+}
+
+func (dc ionDeltaCodec) word(src []byte) uint64 {
+	var buf [8]byte
+	if hostIsBigEndian() {
+		copy(buf[8-dc.width:], src[:dc.width])
+		return binary.BigEndian.Uint64(buf[:])
+	}
+	copy(buf[:], src[:dc.width])
+	return binary.LittleEndian.Uint64(buf[:])
+}
+
+func (dc ionDeltaCodec) putWord(dst []byte, v uint64) {
+	var buf [8]byte
+	if hostIsBigEndian() {
+		binary.BigEndian.PutUint64(buf[:], v)
+		copy(dst[:dc.width], buf[8-dc.width:])
+		return
+	}
+	binary.LittleEndian.PutUint64(buf[:], v)
+	copy(dst[:dc.width], buf[:])
+}
+
+// signed widens the integer v of the codec width with its sign.
+func (dc ionDeltaCodec) signed(v uint64) int64 {
+	shift := 64 - 8*dc.width
+	return int64(v<<shift) >> shift
+}
+
+func (dc ionDeltaCodec) Encode(dst []byte, src []byte) []byte {
+	words := dc.words(len(src))
+	prev := int64(0)
+	for i := 0; i < words; i++ {
+		v := dc.signed(dc.word(src[i*dc.width:]))
+		dst = binary.AppendVarint(dst, v-prev)
+		prev = v
+	}
+	return append(dst, src[words*dc.width:]...)
+}
+
+func (dc ionDeltaCodec) Decode(dst []byte, src []byte) IonErr {
+	words := dc.words(len(dst))
+	prev := int64(0)
+	i := 0
+	for w := 0; w < words; w++ {
+		delta, n := binary.Varint(src[i:])
+		if n <= 0 {
+			return ErrCorruptedData
+		}
+		i += n
+		prev += delta
+		dc.putWord(dst[w*dc.width:], uint64(prev))
+	}
+	if len(src)-i != len(dst)-words*dc.width {
+		return ErrCorruptedData
+	}
+	copy(dst[words*dc.width:], src[i:])
+	return ErrOk
+}
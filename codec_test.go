@@ -0,0 +1,277 @@
+package iondb
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func codecInputs() map[string][]byte {
+	r := rand.New(rand.NewSource(1))
+	random := make([]byte, 300)
+	r.Read(random)
+	header := bytes.Repeat([]byte("SENSOR-07|temp|"), 20)
+	counters := make([]byte, 256)
+	for i := 0; i < len(counters); i += 4 {
+		counters[i] = byte(100 + i/8)
+	}
+	long := bytes.Repeat([]byte{1, 2, 3}, 30000)
+	long = append(long, random...)
+	return map[string][]byte{
+		"empty":    {},
+		"short":    []byte("abc"),
+		"zeros":    make([]byte, 100),
+		"random":   random,
+		"header":   header,
+		"counters": counters,
+		"odd tail": append(counters[:13:13], 9),
+		"long":     long,
+	}
+}
+
+func TestValueCodecs(t *testing.T) {
+	codecs := map[string]IonValueCodec{
+		"lz":      NewLZCodec(),
+		"delta 2": NewDeltaCodec(2),
+		"delta 4": NewDeltaCodec(4),
+		"delta 8": NewDeltaCodec(8),
+		"delta 0": NewDeltaCodec(0),
+		"delta 3": NewDeltaCodec(3),
+		"delta 9": NewDeltaCodec(9),
+	}
+	for codecName, codec := range codecs {
+		for inputName, input := range codecInputs() {
+			codec, input := codec, input
+			t.Run(codecName+"/"+inputName, func(t *testing.T) {
+				enc := codec.Encode(nil, input)
+				got := make([]byte, len(input))
+				if err := codec.Decode(got, enc); err != ErrOk {
+					t.Fatalf("got err = %v, want = %v", err, ErrOk)
+				}
+				if !bytes.Equal(got, input) {
+					t.Fatalf("got decoded = %v, want = %v", got, input)
+				}
+				// every truncation of a short encoding, some of a long one.
+				step := len(enc)/256 + 1
+				for cut := 0; cut < len(enc) && len(input) > 0; cut += step {
+					if err := codec.Decode(got, enc[:cut]); err != ErrCorruptedData {
+						t.Fatalf("got err = %v for %v of %v bytes, want = %v", err, cut, len(enc), ErrCorruptedData)
+					}
+				}
+			})
+		}
+	}
+
+	tests := []struct {
+		name  string
+		codec IonValueCodec
+		input string
+	}{
+		{name: "lz header", codec: NewLZCodec(), input: "header"},
+		{name: "lz zeros", codec: NewLZCodec(), input: "zeros"},
+		{name: "lz long", codec: NewLZCodec(), input: "long"},
+		{name: "delta counters", codec: NewDeltaCodec(4), input: "counters"},
+	}
+	for _, tt := range tests {
+		input := codecInputs()[tt.input]
+		if got := len(tt.codec.Encode(nil, input)); got >= len(input)/2 {
+			t.Errorf("got %v encoded to %v bytes, want < %v", tt.name, got, len(input)/2)
+		}
+	}
+}
+
+func TestSkipListValueCodec(t *testing.T) {
+	type reading [64]byte
+	value := func(n int) reading {
+		var val reading
+		copy(val[:], "SENSOR-07|")
+		val[20] = byte(n)
+		val[40] = byte(n >> 8)
+		return val
+	}
+
+	tests := []struct {
+		name  string
+		codec IonValueCodec
+		opts  []IonDictionaryOption
+		// most of the raw bytes the values may be stored in.
+		ratio float64
+	}{
+		{name: "lz", codec: NewLZCodec(), ratio: 0.5},
+		{name: "delta", codec: NewDeltaCodec(8), ratio: 1},
+		// any other width stores the values as they are.
+		{name: "delta 0", codec: NewDeltaCodec(0), ratio: 1.1},
+		{name: "lz slab", codec: NewLZCodec(), opts: []IonDictionaryOption{WithSlabAllocation(8)}, ratio: 0.5},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]IonDictionaryOption{WithSeed(2), WithValueCodec(tt.codec)}, tt.opts...)
+			dict := NewSkipList[int, reading](-1, KeyTypeNumericSigned, 8, 64, 7, opts...)
+			for key := 0; key < 100; key++ {
+				dict.Insert(key%40, value(key))
+			}
+
+			if got := dict.Get(7); got != value(7) || dict.LastStatus.Err != ErrOk {
+				t.Errorf("got get(7) = %v, %v, want = %v", got, dict.LastStatus.Err, value(7))
+			}
+			if got := dict.GetAll(5); len(got) != 3 || got[0] != value(5) || got[1] != value(45) || got[2] != value(85) {
+				t.Errorf("got getAll(5) = %v", got)
+			}
+			if status := dict.UpdateValue(5, value(45), value(1000)); status.Err != ErrOk {
+				t.Errorf("got updateValue err = %v, want = %v", status.Err, ErrOk)
+			}
+			if status := dict.DeleteValue(5, value(85)); status.Err != ErrOk {
+				t.Errorf("got deleteValue err = %v, want = %v", status.Err, ErrOk)
+			}
+			if got := dict.GetAll(5); len(got) != 2 || got[0] != value(5) || got[1] != value(1000) {
+				t.Errorf("got getAll(5) after changes = %v", got)
+			}
+			dict.Update(6, value(600))
+			dict.DeleteRecord(8)
+
+			cursor := dict.Range(6, 9)
+			var got []reading
+			for cursor.Next(); cursor.HasNext(); cursor.Next() {
+				got = append(got, cursor.GetValue())
+			}
+			want := []reading{value(600), value(600), value(600), value(7), value(47), value(87), value(9), value(49), value(89)}
+			if len(got) != len(want) {
+				t.Fatalf("got range = %v values, want = %v", len(got), len(want))
+			}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Errorf("got range value %v = %v, want = %v", i, got[i], want[i])
+				}
+			}
+			if _, val, ok := dict.Select(1); !ok || val != value(40) {
+				t.Errorf("got select(1) = %v, %v, want = %v", val, ok, value(40))
+			}
+
+			stats := dict.CompressionStats()
+			records := dict.MemoryUsage().Records
+			if stats.Values != records || stats.RawBytes != records*64 {
+				t.Errorf("got stats = %+v, want %v values", stats, records)
+			}
+			if limit := int(float64(stats.RawBytes) * tt.ratio); stats.StoredBytes >= limit {
+				t.Errorf("got stored bytes = %v, want < %v", stats.StoredBytes, limit)
+			}
+
+			// exports hold the values as they were written.
+			var buf bytes.Buffer
+			if err := dict.Export(&buf); err != ErrOk {
+				t.Fatalf("got export err = %v, want = %v", err, ErrOk)
+			}
+			plain := NewSkipList[int, reading](-1, KeyTypeNumericSigned, 8, 64, 7)
+			if err := plain.Import(&buf); err != ErrOk {
+				t.Fatalf("got import err = %v, want = %v", err, ErrOk)
+			}
+			if got := plain.GetAll(5); len(got) != 2 || got[1] != value(1000) {
+				t.Errorf("got imported getAll(5) = %v", got)
+			}
+
+			if _, err := dict.Compact(); err != ErrOk {
+				t.Fatalf("got compact err = %v, want = %v", err, ErrOk)
+			}
+			if got := dict.CompressionStats(); got != stats {
+				t.Errorf("got stats after compact = %+v, want = %+v", got, stats)
+			}
+			if got := dict.Get(99 % 40); got != value(19) {
+				t.Errorf("got get(19) after compact = %v, want = %v", got, value(19))
+			}
+			if err := dict.Validate(); err != nil {
+				t.Errorf("got invalid skip list: %v", err)
+			}
+
+			dict.DeleteBatch([]int{0, 1, 2})
+			if got := dict.CompressionStats().Values; got != dict.MemoryUsage().Records {
+				t.Errorf("got values = %v, want = %v", got, dict.MemoryUsage().Records)
+			}
+		})
+	}
+}
+
+func TestValueCodecPointers(t *testing.T) {
+	type reading struct {
+		ID    int
+		Temps [4]float32
+	}
+	type labelled struct {
+		ID    int
+		Label string
+	}
+	lz := WithValueCodec(NewLZCodec())
+	tests := []struct {
+		name string
+		got  IonErr
+		want IonErr
+	}{
+		{name: "int", got: NewSkipList[int, int](-1, KeyTypeNumericSigned, 8, 8, 7, lz).LastStatus.Err, want: ErrOk},
+		{name: "struct", got: NewSkipList[int, reading](-1, KeyTypeNumericSigned, 8, 24, 7, lz).LastStatus.Err, want: ErrOk},
+		{name: "string", got: NewSkipList[int, string](-1, KeyTypeNumericSigned, 8, 16, 7, lz).LastStatus.Err, want: ErrUnableToConvert},
+		{name: "struct with string", got: NewSkipList[int, labelled](-1, KeyTypeNumericSigned, 8, 24, 7, lz).LastStatus.Err, want: ErrUnableToConvert},
+		{name: "pointer", got: NewSkipList[int, *int](-1, KeyTypeNumericSigned, 8, 8, 7, lz).LastStatus.Err, want: ErrUnableToConvert},
+		{name: "string without codec", got: NewSkipList[int, string](-1, KeyTypeNumericSigned, 8, 16, 7).LastStatus.Err, want: ErrOk},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("got %v err = %v, want = %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func FuzzLZCodec(f *testing.F) {
+	f.Add([]byte("SENSOR-07|SENSOR-07|SENSOR-07|"), []byte{0x10, 'a'})
+	f.Add(bytes.Repeat([]byte{0}, 40), []byte{0xf0, 0xff, 1})
+	f.Fuzz(func(t *testing.T, input []byte, garbage []byte) {
+		codec := NewLZCodec()
+		got := make([]byte, len(input))
+		if err := codec.Decode(got, codec.Encode(nil, input)); err != ErrOk || !bytes.Equal(got, input) {
+			t.Fatalf("got decoded = %v, %v, want = %v", got, err, input)
+		}
+		// any input either decodes or is reported, it never panics.
+		codec.Decode(got, garbage)
+	})
+}
+
+func TestSkipListValueCodecBudget(t *testing.T) {
+	type reading [64]byte
+	var small, large reading
+	small[0] = 1
+	rand.New(rand.NewSource(3)).Read(large[:])
+
+	fill := func(opts ...IonDictionaryOption) *SkipList[int, reading] {
+		opts = append([]IonDictionaryOption{WithSeed(4), WithValueCodec(NewLZCodec())}, opts...)
+		dict := NewSkipList[int, reading](-1, KeyTypeNumericSigned, 8, 64, 7, opts...)
+		dict.Insert(1, small)
+		dict.Insert(1, small)
+		dict.Insert(2, small)
+		return dict
+	}
+	budget := fill().MemoryUsage().Bytes
+
+	tests := []struct {
+		name   string
+		update func(dict *SkipList[int, reading]) IonStatus
+		want   IonErr
+	}{
+		{"update grows", func(dict *SkipList[int, reading]) IonStatus { return dict.Update(1, large) }, ErrMaxCapacity},
+		{"update at grows", func(dict *SkipList[int, reading]) IonStatus { return dict.UpdateAt(2, 0, large) }, ErrMaxCapacity},
+		{"update value grows", func(dict *SkipList[int, reading]) IonStatus { return dict.UpdateValue(1, small, large) }, ErrMaxCapacity},
+		{"same size", func(dict *SkipList[int, reading]) IonStatus { return dict.Update(1, small) }, ErrOk},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dict := fill(WithMemoryBudget(budget))
+			if status := tt.update(dict); status.Err != tt.want {
+				t.Errorf("got err = %v, want = %v", status.Err, tt.want)
+			}
+			if got := dict.MemoryUsage().Bytes; got > budget {
+				t.Errorf("got bytes = %v, want <= %v", got, budget)
+			}
+			if got := dict.GetAll(1); len(got) != 2 || got[0] != small || got[1] != small {
+				t.Errorf("got getAll(1) = %v, want = %v", got, []reading{small, small})
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package iondb
+
+import "unsafe"
+
+// Compact rebuilds the dictionary from its live records, dropping expired
+// ones and packing the rest into fresh storage, and returns how many bytes
+// it reclaimed. The old records stay in place until the rebuild is done, so
+// a compaction that fails leaves the dictionary as it was. Cursors opened
+// before a compaction must not be used after it. Only in-memory storage is
+// rebuilt: there is no file-backed dictionary yet to rewrite into a new
+// file and swap.
+func (sl *SkipList[K, V]) Compact() (int, IonErr) {
+	return sl.CompactUntil(nil)
+}
+
+// CompactUntil compacts like Compact but gives up with ErrInterrupted, and
+// the dictionary as it was, once stop returns true. stop is asked before
+// every record is copied.
+func (sl *SkipList[K, V]) CompactUntil(stop func() bool) (int, IonErr) {
+	reclaimed, err := dictCompact(&(sl.dict), stop)
+	if err == ErrOk {
+		sl.reindex()
+	}
+	sl.LastStatus = IonStatus{Err: err}
+	return reclaimed, err
+}
+
+func dictCompact(dict *IonDictionary, stop func() bool) (int, IonErr) {
+	return (*(dict.handler)).compact(dict, stop)
+}
+
+// slFootprint is the memory the nodes of the skip list hold on to: every
+// slab when nodes come from slabs, the live nodes otherwise.
+func slFootprint(skipList *ionSkipList) int {
+	if skipList.arena != nil {
+		return int(unsafe.Sizeof(ionSlNode{})) + slLinkBytes(skipList.maxheight) + skipList.arena.bytes
+	}
+	return skipList.bytes
+}
+
+// slCompact copies the unexpired nodes, in order and at their height, into
+// a new head and arena, then swaps them in. Slabs holding only freed or
+// expired nodes are left to the garbage collector. When stop interrupts the
+// copy the new head and arena are dropped instead.
+func slCompact(skipList *ionSkipList, stop func() bool) (int, IonErr) {
+	before := slFootprint(skipList)
+
+	fresh := *skipList
+	fresh.head = new(ionSlNode)
+	fresh.head.height = skipList.maxheight - 1
+	fresh.head.next = make([]*ionSlNode, skipList.maxheight)
+	fresh.head.width = make([]int, skipList.maxheight)
+	fresh.records = 0
+	fresh.compressed = IonCompressionStats{}
+	fresh.bytes = int(unsafe.Sizeof(ionSlNode{})) + slLinkBytes(skipList.maxheight)
+	if skipList.arena != nil {
+		fresh.arena = slNewArena(skipList.arena.nodesPerSlab, skipList.super.record.keySize, skipList.super.record.valueSize, skipList.maxheight, skipList.pnum, skipList.pden)
+	}
+
+	last := make([]*ionSlNode, skipList.maxheight)
+	lastRank := make([]int, skipList.maxheight)
+	for h := range last {
+		last[h] = fresh.head
+	}
+	// a duplicate whose tower expired takes over its height.
+	var height ionSlLevel
+	prev := skipList.head
+	for node := skipList.head.next[0]; node != nil; prev, node = node, node.next[0] {
+		if prev == skipList.head || skipList.super.compare(prev.key, node.key, skipList.super.record.keySize) != 0 {
+			height = node.height
+		}
+		if slExpired(skipList, node) {
+			continue
+		}
+		if stop != nil && stop() {
+			return 0, ErrInterrupted
+		}
+		newNode, err := slNewNode(&fresh, node.key, slPlainValue(skipList, node), height, node.expires)
+		if err != ErrOk {
+			return 0, err
+		}
+		height = 0
+		var h ionSlLevel
+		for h = 0; h < skipList.maxheight; h++ {
+			if h > newNode.height {
+				last[h].width[h]++
+				continue
+			}
+			last[h].next[h] = newNode
+			last[h].width[h] = fresh.records - lastRank[h]
+			last[h] = newNode
+			lastRank[h] = fresh.records
+		}
+	}
+
+	skipList.head = fresh.head
+	skipList.arena = fresh.arena
+	skipList.records = fresh.records
+	skipList.bytes = fresh.bytes
+	skipList.compressed = fresh.compressed
+	slDebugCheck(skipList)
+	return before - slFootprint(skipList), ErrOk
+}
@@ -0,0 +1,141 @@
+package iondb
+
+import (
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestCompact(t *testing.T) {
+	one := 1
+	kSize := int(unsafe.Sizeof(one))
+	vSize := uint(unsafe.Sizeof(one))
+
+	tests := []struct {
+		name string
+		opts []IonDictionaryOption
+	}{
+		{name: "heap"},
+		{name: "slab", opts: []IonDictionaryOption{WithSlabAllocation(16)}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(1000, 0)
+			opts := append([]IonDictionaryOption{WithSeed(5), WithClock(func() time.Time { return now })}, tt.opts...)
+			// dict is compacted, same gets the same records and is not.
+			dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, kSize, vSize, 7, opts...)
+			same := NewSkipList[int, int](-1, KeyTypeNumericSigned, kSize, vSize, 7, opts...)
+			for _, sl := range []*SkipList[int, int]{dict, same} {
+				// the first record of 1000 holds the tower of its duplicates and expires.
+				sl.InsertWithTTL(1000, 0, time.Second)
+				sl.Insert(1000, 1)
+				sl.Insert(1000, 2)
+				for key := 0; key < 300; key++ {
+					if key%7 == 0 {
+						sl.InsertWithTTL(key%60, key, time.Second)
+					} else {
+						sl.Insert(key%60, key)
+					}
+				}
+				for key := 0; key < 60; key += 3 {
+					sl.DeleteRecord(key)
+				}
+			}
+			now = now.Add(time.Minute)
+
+			reclaimed, err := dict.Compact()
+			if err != ErrOk {
+				t.Fatalf("got err = %v, want = %v", err, ErrOk)
+			}
+			if reclaimed <= 0 {
+				t.Errorf("got reclaimed = %v, want > 0", reclaimed)
+			}
+			if err := dict.Validate(); err != nil {
+				t.Fatalf("got invalid skip list: %v", err)
+			}
+			var want [][2]int
+			cursor := same.AllRecords()
+			for cursor.Next(); cursor.HasNext(); cursor.Next() {
+				want = append(want, [2]int{cursor.GetKey(), cursor.GetValue()})
+			}
+			var got [][2]int
+			cursor = dict.AllRecords()
+			for cursor.Next(); cursor.HasNext(); cursor.Next() {
+				got = append(got, [2]int{cursor.GetKey(), cursor.GetValue()})
+			}
+			if got := dict.MemoryUsage().Records; got != len(want) {
+				t.Errorf("got records = %v, want = %v", got, len(want))
+			}
+			if len(got) != len(want) {
+				t.Fatalf("got records = %v, want = %v", got, want)
+			}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Fatalf("got record %v = %v, want = %v", i, got[i], want[i])
+				}
+			}
+			if got, want := dict.Rank(1000), len(want)-2; got != want {
+				t.Errorf("got rank(1000) = %v, want = %v", got, want)
+			}
+
+			if reclaimed, err := dict.Compact(); err != ErrOk || reclaimed != 0 {
+				t.Errorf("got second compaction = %v, %v, want = %v, %v", reclaimed, err, 0, ErrOk)
+			}
+			dict.Insert(-1, -1)
+			if err := dict.Validate(); err != nil {
+				t.Errorf("got invalid skip list after insert: %v", err)
+			}
+		})
+	}
+}
+
+func TestCompactUntil(t *testing.T) {
+	one := 1
+	kSize := int(unsafe.Sizeof(one))
+	vSize := uint(unsafe.Sizeof(one))
+
+	tests := []struct {
+		name  string
+		after int
+		want  IonErr
+	}{
+		{name: "interrupted at once", after: 0, want: ErrInterrupted},
+		{name: "interrupted halfway", after: 50, want: ErrInterrupted},
+		{name: "not interrupted", after: 1000, want: ErrOk},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, kSize, vSize, 7, WithSeed(8), WithSlabAllocation(16))
+			for key := 0; key < 200; key++ {
+				dict.Insert(key, key)
+			}
+			for key := 0; key < 200; key += 2 {
+				dict.DeleteRecord(key)
+			}
+			before := dict.MemoryUsage()
+
+			copied := 0
+			reclaimed, err := dict.CompactUntil(func() bool {
+				copied++
+				return copied > tt.after
+			})
+			if err != tt.want {
+				t.Fatalf("got err = %v, want = %v", err, tt.want)
+			}
+			if err := dict.Validate(); err != nil {
+				t.Fatalf("got invalid skip list: %v", err)
+			}
+			if tt.want == ErrInterrupted && (reclaimed != 0 || dict.MemoryUsage() != before) {
+				t.Errorf("got reclaimed, usage = %v, %+v, want = %v, %+v", reclaimed, dict.MemoryUsage(), 0, before)
+			}
+			if got := dict.MemoryUsage().Records; got != 100 {
+				t.Errorf("got records = %v, want = %v", got, 100)
+			}
+			if got := dict.Get(99); got != 99 {
+				t.Errorf("got get(99) = %v, want = %v", got, 99)
+			}
+		})
+	}
+}
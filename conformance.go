@@ -0,0 +1,259 @@
+//go:build conformance
+
+package iondb
+
+import (
+	"math/rand"
+	"testing"
+	"unsafe"
+)
+
+// ConformanceBackend names a dictionary handler for RunConformance. Init
+// fills a handler the way SldictInit does, and DictSize is what the
+// handler is created with. Persistent is set for handlers that keep their
+// records across a close and open.
+type ConformanceBackend struct {
+	Name       string
+	Init       func(handler *IonDictionaryHandler)
+	DictSize   IonDictionarySize
+	Persistent bool
+}
+
+// RunConformance checks that a handler behaves like every other one: signed
+// 8 byte keys and values go through create, insert, duplicates, get, update
+// as upsert, delete, every predicate, the end states of a cursor, a close
+// and open round trip for Persistent handlers, and then through random
+// operations checked against a sorted slice. The harness imports testing,
+// so it is only built with the conformance tag.
+func RunConformance(t *testing.T, backend ConformanceBackend) {
+	t.Run(backend.Name, func(t *testing.T) {
+		t.Run("create", func(t *testing.T) {
+			dict, _ := conformanceCreate(t, backend)
+			if dict.instance.kType != KeyTypeNumericSigned {
+				t.Errorf("got keyType = %v, want = %v", dict.instance.kType, KeyTypeNumericSigned)
+			}
+			if dict.instance.record.keySize != 8 || dict.instance.record.valueSize != 8 {
+				t.Errorf("got sizes = %v, %v, want = %v, %v", dict.instance.record.keySize, dict.instance.record.valueSize, 8, 8)
+			}
+			conformanceExpect(t, dict, new(IonPredicateAllRecords), nil)
+		})
+
+		t.Run("insert and get", func(t *testing.T) {
+			dict, model := conformanceCreate(t, backend)
+			for _, key := range []int64{5, -3, 12, 0, 7} {
+				conformanceStatus(t, "insert", dictInsert(dict, cfKey(key), cfVal(key*10)), IonStatus{ErrOk, 1})
+				model.insert(key, key*10)
+			}
+			for _, key := range []int64{5, -3, 12, 0, 7} {
+				conformanceGet(t, dict, model, key)
+			}
+			conformanceGet(t, dict, model, 99)
+			conformanceExpect(t, dict, new(IonPredicateAllRecords), model.records)
+		})
+
+		t.Run("duplicates", func(t *testing.T) {
+			dict, model := conformanceCreate(t, backend)
+			for _, rec := range []conformanceRecord{{4, 1}, {4, 2}, {2, 0}, {4, 3}, {6, 0}} {
+				conformanceStatus(t, "insert", dictInsert(dict, cfKey(rec.key), cfVal(rec.val)), IonStatus{ErrOk, 1})
+				model.insert(rec.key, rec.val)
+			}
+			// the first duplicate inserted is the one get returns.
+			conformanceGet(t, dict, model, 4)
+			conformanceExpect(t, dict, cfEquality(4), model.equality(4))
+			conformanceExpect(t, dict, new(IonPredicateAllRecords), model.records)
+		})
+
+		t.Run("update as upsert", func(t *testing.T) {
+			dict, model := conformanceCreate(t, backend)
+			dictInsert(dict, cfKey(1), cfVal(1))
+			dictInsert(dict, cfKey(1), cfVal(2))
+			model.insert(1, 1)
+			model.insert(1, 2)
+			conformanceStatus(t, "update", dictUpdate(dict, cfKey(1), cfVal(9)), IonStatus{ErrOk, 2})
+			model.update(1, 9)
+			conformanceStatus(t, "upsert", dictUpdate(dict, cfKey(3), cfVal(30)), IonStatus{ErrOk, 1})
+			model.update(3, 30)
+			conformanceExpect(t, dict, new(IonPredicateAllRecords), model.records)
+		})
+
+		t.Run("delete", func(t *testing.T) {
+			dict, model := conformanceCreate(t, backend)
+			for _, key := range []int64{1, 2, 2, 3} {
+				dictInsert(dict, cfKey(key), cfVal(key))
+				model.insert(key, key)
+			}
+			conformanceStatus(t, "delete", dictDelete(dict, cfKey(2)), IonStatus{ErrOk, 2})
+			model.remove(2)
+			conformanceStatus(t, "delete missing", dictDelete(dict, cfKey(2)), IonStatus{ErrItemNotFound, 0})
+			conformanceGet(t, dict, model, 2)
+			conformanceExpect(t, dict, new(IonPredicateAllRecords), model.records)
+		})
+
+		t.Run("predicates", func(t *testing.T) {
+			dict, model := conformanceCreate(t, backend)
+			for key := int64(-10); key < 10; key += 2 {
+				dictInsert(dict, cfKey(key), cfVal(key))
+				model.insert(key, key)
+			}
+			conformanceExpect(t, dict, cfEquality(4), model.equality(4))
+			conformanceExpect(t, dict, cfEquality(5), nil)
+			conformanceExpect(t, dict, cfRange(-3, 5), model.between(-3, 5))
+			conformanceExpect(t, dict, cfRange(-10, 8), model.between(-10, 8))
+			conformanceExpect(t, dict, cfRange(11, 20), nil)
+			conformanceExpect(t, dict, new(IonPredicateAllRecords), model.records)
+		})
+
+		t.Run("cursor end states", func(t *testing.T) {
+			dict, _ := conformanceCreate(t, backend)
+			dictInsert(dict, cfKey(1), cfVal(1))
+
+			var cursor *IonDictCursor
+			if err := dictFind(dict, cfEquality(2), &cursor); err != ErrOk {
+				t.Fatalf("got err = %v, want = %v", err, ErrOk)
+			}
+			record := conformanceRecordBuffer(dict)
+			for i := 0; i < 2; i++ {
+				if status := cursor.next(cursor, &record); status != csEndOfResults {
+					t.Errorf("got empty cursor status = %v, want = %v", status, csEndOfResults)
+				}
+			}
+			cursor.destroy(&cursor)
+			if cursor != nil {
+				t.Errorf("got destroyed cursor = %v, want = %v", cursor, nil)
+			}
+
+			if err := dictFind(dict, new(IonPredicateAllRecords), &cursor); err != ErrOk {
+				t.Fatalf("got err = %v, want = %v", err, ErrOk)
+			}
+			if status := cursor.next(cursor, &record); status != csCursorActive {
+				t.Errorf("got cursor status = %v, want = %v", status, csCursorActive)
+			}
+			for i := 0; i < 2; i++ {
+				if status := cursor.next(cursor, &record); status != csEndOfResults {
+					t.Errorf("got exhausted cursor status = %v, want = %v", status, csEndOfResults)
+				}
+			}
+			cursor.destroy(&cursor)
+		})
+
+		t.Run("open close round trip", func(t *testing.T) {
+			if !backend.Persistent {
+				t.Skip("the handler does not keep records across a close")
+			}
+			dict, model := conformanceCreate(t, backend)
+			for key := int64(0); key < 5; key++ {
+				dictInsert(dict, cfKey(key), cfVal(key))
+				model.insert(key, key)
+			}
+			conf := dictConfig(dict)
+			conf.dictSize = backend.DictSize
+			handler := dict.handler
+			if err := dictClose(dict); err != ErrOk {
+				t.Fatalf("got close err = %v, want = %v", err, ErrOk)
+			}
+			var reopened IonDictionary
+			if err := dictOpen(handler, &reopened, &conf); err != ErrOk {
+				t.Fatalf("got open err = %v, want = %v", err, ErrOk)
+			}
+			conformanceExpect(t, &reopened, new(IonPredicateAllRecords), model.records)
+		})
+
+		t.Run("random operations", func(t *testing.T) {
+			dict, model := conformanceCreate(t, backend)
+			r := rand.New(rand.NewSource(41))
+			for op := 0; op < 2000; op++ {
+				key := int64(r.Intn(60) - 30)
+				val := int64(r.Intn(1000))
+				switch r.Intn(7) {
+				case 0, 1:
+					conformanceStatus(t, "insert", dictInsert(dict, cfKey(key), cfVal(val)), IonStatus{ErrOk, 1})
+					model.insert(key, val)
+				case 2:
+					conformanceGet(t, dict, model, key)
+				case 3:
+					conformanceStatus(t, "update", dictUpdate(dict, cfKey(key), cfVal(val)), model.update(key, val))
+				case 4:
+					conformanceStatus(t, "delete", dictDelete(dict, cfKey(key)), model.remove(key))
+				case 5:
+					hi := key + int64(r.Intn(20))
+					conformanceExpect(t, dict, cfRange(key, hi), model.between(key, hi))
+				case 6:
+					conformanceExpect(t, dict, cfEquality(key), model.equality(key))
+				}
+				if t.Failed() {
+					t.Fatalf("failed at operation %v", op)
+				}
+			}
+			conformanceExpect(t, dict, new(IonPredicateAllRecords), model.records)
+		})
+	})
+}
+
+func conformanceCreate(t *testing.T, backend ConformanceBackend) (*IonDictionary, *conformanceModel) {
+	t.Helper()
+	handler := new(IonDictionaryHandler)
+	backend.Init(handler)
+	dict := new(IonDictionary)
+	if err := dictCreate(handler, dict, -1, KeyTypeNumericSigned, 8, 8, backend.DictSize); err != ErrOk {
+		t.Fatalf("got create err = %v, want = %v", err, ErrOk)
+	}
+	return dict, &conformanceModel{compare: dict.instance.compare}
+}
+
+func conformanceRecordBuffer(dict *IonDictionary) IonRecord {
+	var record IonRecord
+	record.key = IonKey(alloc(uintptr(dict.instance.record.keySize), nil))
+	record.value = IonValue(alloc(uintptr(dict.instance.record.valueSize), nil))
+	return record
+}
+
+func conformanceStatus(t *testing.T, op string, got IonStatus, want IonStatus) {
+	t.Helper()
+	if got != want {
+		t.Errorf("got %v status = %v, want = %v", op, got, want)
+	}
+}
+
+func conformanceGet(t *testing.T, dict *IonDictionary, model *conformanceModel, key int64) {
+	t.Helper()
+	var val int64
+	status := dictGet(dict, cfKey(key), IonValue(unsafe.Pointer(&val)))
+	want := model.equality(key)
+	if len(want) == 0 {
+		conformanceStatus(t, "get", status, IonStatus{ErrItemNotFound, 0})
+		return
+	}
+	conformanceStatus(t, "get", status, IonStatus{ErrOk, 1})
+	if val != want[0].val {
+		t.Errorf("got get(%v) = %v, want = %v", key, val, want[0].val)
+	}
+}
+
+// conformanceExpect runs predicate to its end and compares the records with want.
+func conformanceExpect(t *testing.T, dict *IonDictionary, predicate IonPredicate, want []conformanceRecord) {
+	t.Helper()
+	var cursor *IonDictCursor
+	if err := dictFind(dict, predicate, &cursor); err != ErrOk {
+		t.Fatalf("got find err = %v, want = %v", err, ErrOk)
+	}
+	record := conformanceRecordBuffer(dict)
+	var got []conformanceRecord
+	status := cursor.next(cursor, &record)
+	for ; status == csCursorActive; status = cursor.next(cursor, &record) {
+		got = append(got, conformanceRecord{*(*int64)(record.key), *(*int64)(record.value)})
+	}
+	cursor.destroy(&cursor)
+	if status != csEndOfResults {
+		t.Errorf("got final cursor status = %v, want = %v", status, csEndOfResults)
+	}
+	if len(got) != len(want) {
+		t.Errorf("got records = %v, want = %v", got, want)
+		return
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("got records = %v, want = %v", got, want)
+			return
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package iondb
+
+import "unsafe"
+
+type conformanceRecord struct {
+	key int64
+	val int64
+}
+
+// conformanceModel is the reference a handler is checked against: records
+// sorted by the dictionary's comparator, duplicates in insertion order.
+type conformanceModel struct {
+	compare IonDictionaryCompare
+	records []conformanceRecord
+}
+
+func (model *conformanceModel) cmp(a, b int64) int8 {
+	return model.compare(IonKey(unsafe.Pointer(&a)), IonKey(unsafe.Pointer(&b)), 8)
+}
+
+func (model *conformanceModel) insert(key, val int64) {
+	i := 0
+	for i < len(model.records) && model.cmp(model.records[i].key, key) <= 0 {
+		i++
+	}
+	model.records = append(model.records, conformanceRecord{})
+	copy(model.records[i+1:], model.records[i:])
+	model.records[i] = conformanceRecord{key, val}
+}
+
+func (model *conformanceModel) update(key, val int64) IonStatus {
+	status := IonStatus{ErrOk, 0}
+	for i := range model.records {
+		if model.cmp(model.records[i].key, key) == 0 {
+			model.records[i].val = val
+			status.ResCnt++
+		}
+	}
+	if status.ResCnt == 0 {
+		model.insert(key, val)
+		status.ResCnt = 1
+	}
+	return status
+}
+
+func (model *conformanceModel) remove(key int64) IonStatus {
+	status := IonStatus{ErrItemNotFound, 0}
+	kept := model.records[:0]
+	for _, rec := range model.records {
+		if model.cmp(rec.key, key) == 0 {
+			status.ResCnt++
+			continue
+		}
+		kept = append(kept, rec)
+	}
+	model.records = kept
+	if status.ResCnt > 0 {
+		status.Err = ErrOk
+	}
+	return status
+}
+
+func (model *conformanceModel) equality(key int64) []conformanceRecord {
+	return model.between(key, key)
+}
+
+func (model *conformanceModel) between(lo, hi int64) []conformanceRecord {
+	var found []conformanceRecord
+	for _, rec := range model.records {
+		if model.cmp(rec.key, lo) >= 0 && model.cmp(rec.key, hi) <= 0 {
+			found = append(found, rec)
+		}
+	}
+	return found
+}
+
+func cfKey(key int64) IonKey {
+	return IonKey(unsafe.Pointer(&key))
+}
+
+func cfVal(val int64) IonValue {
+	return IonValue(unsafe.Pointer(&val))
+}
+
+func cfEquality(key int64) IonPredicate {
+	predicate := new(IonPredicateEquality)
+	predicate.equalityVal = cfKey(key)
+	return predicate
+}
+
+func cfRange(lo, hi int64) IonPredicate {
+	predicate := new(IonPredicateRange)
+	predicate.lowerBound = cfKey(lo)
+	predicate.upperBound = cfKey(hi)
+	return predicate
+}
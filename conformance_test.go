@@ -0,0 +1,14 @@
+//go:build conformance
+
+package iondb
+
+import "testing"
+
+func TestConformance(t *testing.T) {
+	backends := []ConformanceBackend{
+		{Name: "skiplist", Init: SldictInit, DictSize: 7},
+	}
+	for _, backend := range backends {
+		RunConformance(t, backend)
+	}
+}
@@ -1,19 +1,38 @@
 package iondb
 
-import "unsafe"
+import (
+	"bytes"
+	"io"
+	"sort"
+	"time"
+	"unsafe"
+)
 
 type Dictionary[K, V any] interface {
 	Insert(key K, val V) IonStatus
 	Get(key K) V
 	DeleteRecord(key K) IonStatus
-	Update(key K, value V)
+	Update(key K, value V) IonStatus
+	GetAll(key K) []V
+	DeleteValue(key K, val V) IonStatus
+	UpdateAt(key K, pos int, val V) IonStatus
+	UpdateValue(key K, oldVal V, newVal V) IonStatus
 	DeleteDictionary() IonErr
-	DestroyDIctionary(id IonDictionaryID) IonErr
+	DestroyDictionary(id IonDictionaryID) IonErr
 	Open(confInfo IonDictionaryConfigInfo) IonErr
 	Close() IonErr
 	Range(minKey, maxKey K) *Cursor[K, V]
 	Equality(key K) *Cursor[K, V]
-	AllRecord() *Cursor[K, V]
+	AllRecords() *Cursor[K, V]
+	Export(w io.Writer) IonErr
+	Import(r io.Reader) IonErr
+	BulkLoad(next func() (K, V, bool)) IonStatus
+	InsertBatch(keys []K, vals []V) []IonStatus
+	GetBatch(keys []K) ([]V, []IonStatus)
+	DeleteBatch(keys []K) []IonStatus
+	Dump(w io.Writer, format func(key K) string) IonErr
+	WriteDot(w io.Writer, format func(key K) string) IonErr
+	Compact() (int, IonErr)
 }
 
 type IonDictionary struct {
@@ -24,6 +43,7 @@ type IonDictionary struct {
 
 type IonDictionaryHandler interface {
 	insert(dict *IonDictionary, key IonKey, val IonValue) IonStatus
+	insertTTL(dict *IonDictionary, key IonKey, val IonValue, ttl time.Duration) IonStatus
 	createDictionary(id IonDictionaryID, kType IonKeyType, kSize IonKeySize, vSize IonValueSize, dictSize IonDictionarySize, compare IonDictionaryCompare, handler *IonDictionaryHandler, dict *IonDictionary) IonErr
 	get(dict *IonDictionary, key IonKey, val IonValue) IonStatus
 	update(dict *IonDictionary, key IonKey, val IonValue) IonStatus
@@ -33,8 +53,19 @@ type IonDictionaryHandler interface {
 	destroyDictionary(id IonDictionaryID) IonErr
 	openDictionary(handler *IonDictionaryHandler, dict *IonDictionary, conf *IonDictionaryConfigInfo, compare IonDictionaryCompare) IonErr
 	closeDictionary(dict *IonDictionary) IonErr
+	bulkLoad(dict *IonDictionary, next func(record *IonRecord) bool) IonStatus
+	batch(dict *IonDictionary, op ionBatchOp, records []IonRecord, statuses []IonStatus) IonErr
+	updateOne(dict *IonDictionary, key IonKey, match ionDuplicateMatch, val IonValue) IonStatus
+	removeOne(dict *IonDictionary, key IonKey, match ionDuplicateMatch) IonStatus
+	dump(dict *IonDictionary, w io.Writer, format IonKeyFormatter) IonErr
+	writeDot(dict *IonDictionary, w io.Writer, format IonKeyFormatter) IonErr
+	compact(dict *IonDictionary, stop func() bool) (int, IonErr)
 }
 
+// ionDuplicateMatch picks one record among the duplicates of a key. pos
+// counts the duplicates from 0 in the order they were inserted.
+type ionDuplicateMatch func(pos int, val IonValue) bool
+
 // A type used to identify dictionaries, specifically in the master table.
 type IonDictionaryID = int
 
@@ -58,15 +89,40 @@ func dictCreate(handler *IonDictionaryHandler, dict *IonDictionary, id IonDictio
 }
 
 func dictInsert(dict *IonDictionary, key IonKey, val IonValue) IonStatus {
-	return (*(dict.handler)).insert(dict, key, val)
+	status := (*(dict.handler)).insert(dict, key, val)
+	if status.Err == ErrOk {
+		dictBloomAdd(dict, key)
+	}
+	dictBloomCheck(dict)
+	return status
+}
+
+// dictInsertTTL inserts a record that expires ttl from now instead of after
+// the dictionary's own TTL.
+func dictInsertTTL(dict *IonDictionary, key IonKey, val IonValue, ttl time.Duration) IonStatus {
+	status := (*(dict.handler)).insertTTL(dict, key, val, ttl)
+	if status.Err == ErrOk {
+		dictBloomAdd(dict, key)
+	}
+	dictBloomCheck(dict)
+	return status
 }
 
 func dictGet(dict *IonDictionary, key IonKey, val IonValue) IonStatus {
+	if !dictBloomMayContain(dict, key) {
+		return IonStatus{ErrItemNotFound, 0}
+	}
 	return (*(dict.handler)).get(dict, key, val)
 }
 
 func dictUpdate(dict *IonDictionary, key IonKey, val IonValue) IonStatus {
-	return (*(dict.handler)).update(dict, key, val)
+	status := (*(dict.handler)).update(dict, key, val)
+	if status.Err == ErrOk {
+		// update inserts the key when it is missing.
+		dictBloomAdd(dict, key)
+	}
+	dictBloomCheck(dict)
+	return status
 }
 
 func dictDeleteDictionary(dict *IonDictionary) IonErr {
@@ -82,7 +138,134 @@ func dictDestroyDictionary(handler *IonDictionaryHandler, id IonDictionaryID) Io
 }
 
 func dictDelete(dict *IonDictionary, key IonKey) IonStatus {
-	return (*(dict.handler)).remove(dict, key)
+	status := (*(dict.handler)).remove(dict, key)
+	dictBloomDeleted(dict, status.ResCnt)
+	return status
+}
+
+// dictUpdateOne sets the value of the first duplicate of key that match picks.
+func dictUpdateOne(dict *IonDictionary, key IonKey, match ionDuplicateMatch, val IonValue) IonStatus {
+	return (*(dict.handler)).updateOne(dict, key, match, val)
+}
+
+// dictDeleteOne removes the first duplicate of key that match picks.
+func dictDeleteOne(dict *IonDictionary, key IonKey, match ionDuplicateMatch) IonStatus {
+	status := (*(dict.handler)).removeOne(dict, key, match)
+	dictBloomDeleted(dict, status.ResCnt)
+	return status
+}
+
+// dictMatchPosition picks the duplicate at pos.
+func dictMatchPosition(pos int) ionDuplicateMatch {
+	return func(p int, val IonValue) bool {
+		return p == pos
+	}
+}
+
+// dictBulkLoad inserts the records produced by next, which must come in
+// ascending key order. Handlers that can't build their structure from sorted
+// input return ErrNotImplemented and get one insert per record instead.
+// Records before an out of order key stay inserted.
+func dictBulkLoad(dict *IonDictionary, next func(record *IonRecord) bool) IonStatus {
+	if dict.instance.bloom != nil {
+		// a key that ends up rejected only costs a false positive.
+		unfiltered := next
+		next = func(record *IonRecord) bool {
+			if !unfiltered(record) {
+				return false
+			}
+			dictBloomAdd(dict, record.key)
+			return true
+		}
+	}
+	status := (*(dict.handler)).bulkLoad(dict, next)
+	if status.Err != ErrNotImplemented {
+		return status
+	}
+
+	status = IonStatus{ErrOk, 0}
+	kSize := dict.instance.record.keySize
+	var record IonRecord
+	prevKey := IonKey(alloc(uintptr(kSize), nil))
+	for next(&record) {
+		if status.ResCnt > 0 && dict.instance.compare(record.key, prevKey, kSize) < 0 {
+			status.Err = ErrSortedOrderViolation
+			return status
+		}
+		insertStatus := dictInsert(dict, record.key, record.value)
+		if insertStatus.Err != ErrOk {
+			status.Err = insertStatus.Err
+			return status
+		}
+		memcpy(unsafe.Pointer(prevKey), unsafe.Pointer(record.key), uintptr(kSize))
+		status.ResCnt++
+	}
+	return status
+}
+
+// An operation applied to every record of a batch.
+type ionBatchOp int8
+
+const (
+	batchInsert = iota
+	batchGet
+	batchDelete
+)
+
+// dictBatch applies op to every record and returns the statuses in the order
+// of records. The handler sees the records sorted by key, so it can carry its
+// search position from one key to the next. Handlers without batch support
+// return ErrNotImplemented and get one call per record instead.
+func dictBatch(dict *IonDictionary, op ionBatchOp, records []IonRecord) []IonStatus {
+	kSize := dict.instance.record.keySize
+	order := make([]int, len(records))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return dict.instance.compare(records[order[i]].key, records[order[j]].key, kSize) < 0
+	})
+
+	sorted := make([]IonRecord, len(records))
+	for i, idx := range order {
+		sorted[i] = records[idx]
+	}
+	sortedStatuses := make([]IonStatus, len(records))
+
+	err := (*(dict.handler)).batch(dict, op, sorted, sortedStatuses)
+	if err == ErrOk {
+		for i := range sorted {
+			switch op {
+			case batchInsert:
+				if sortedStatuses[i].Err == ErrOk {
+					dictBloomAdd(dict, sorted[i].key)
+				}
+			case batchDelete:
+				dictBloomDeleted(dict, sortedStatuses[i].ResCnt)
+			}
+		}
+	} else if err == ErrNotImplemented {
+		for i := range sorted {
+			switch op {
+			case batchInsert:
+				sortedStatuses[i] = dictInsert(dict, sorted[i].key, sorted[i].value)
+			case batchGet:
+				sortedStatuses[i] = dictGet(dict, sorted[i].key, sorted[i].value)
+			case batchDelete:
+				sortedStatuses[i] = dictDelete(dict, sorted[i].key)
+			}
+		}
+	} else if err != ErrOk {
+		for i := range sortedStatuses {
+			sortedStatuses[i] = IonStatus{err, 0}
+		}
+	}
+
+	statuses := make([]IonStatus, len(records))
+	for i, idx := range order {
+		statuses[idx] = sortedStatuses[i]
+	}
+	return statuses
 }
 
 type IonDictionaryStatus int8
@@ -102,6 +285,78 @@ type IonDictionaryConfigInfo struct {
 	dictSize   IonDictionarySize
 	dictType   IonDictionaryType
 	dictStatus IonDictionaryStatus
+	uniqueKeys bool
+	ttl        time.Duration
+	clock      func() time.Time
+	// a dictionary opened with bloomItems set builds its own filter, or
+	// takes bloomState, the filter as it was when the config was taken.
+	bloomItems  int
+	bloomFPRate float64
+	bloomState  []byte
+	// 0 means no budget.
+	memoryBudget int
+	recordBudget int
+	// skip list level generation, 0 keeps the default.
+	seed      int64
+	seeded    bool
+	pnum      int
+	pden      int
+	maxHeight IonDictionarySize
+	slabNodes int
+	codec     IonValueCodec
+}
+
+// IonDictionaryOption sets an optional part of the configuration a
+// dictionary is created with.
+type IonDictionaryOption func(conf *IonDictionaryConfigInfo)
+
+// WithTTL makes every record expire ttl after it is inserted or updated.
+// Expired records are hidden from reads and removed lazily.
+func WithTTL(ttl time.Duration) IonDictionaryOption {
+	return func(conf *IonDictionaryConfigInfo) {
+		conf.ttl = ttl
+	}
+}
+
+// WithClock replaces time.Now as the clock records expire by.
+func WithClock(clock func() time.Time) IonDictionaryOption {
+	return func(conf *IonDictionaryConfigInfo) {
+		conf.clock = clock
+	}
+}
+
+// WithBloomFilter puts a Bloom filter sized for expectedItems keys with a
+// false positive rate of fpRate in front of Get, so most lookups of missing
+// keys don't reach the handler.
+func WithBloomFilter(expectedItems int, fpRate float64) IonDictionaryOption {
+	return func(conf *IonDictionaryConfigInfo) {
+		conf.bloomItems = expectedItems
+		conf.bloomFPRate = fpRate
+	}
+}
+
+// WithMemoryBudget caps the bytes an in-memory dictionary may hold in
+// records and their links. An insert past it fails with ErrMaxCapacity.
+func WithMemoryBudget(bytes int) IonDictionaryOption {
+	return func(conf *IonDictionaryConfigInfo) {
+		conf.memoryBudget = bytes
+	}
+}
+
+// WithRecordBudget caps how many records an in-memory dictionary may hold.
+// An insert past it fails with ErrMaxCapacity.
+func WithRecordBudget(records int) IonDictionaryOption {
+	return func(conf *IonDictionaryConfigInfo) {
+		conf.recordBudget = records
+	}
+}
+
+// WithUniqueKeys makes Insert reject a key that is already stored with
+// ErrDuplicateKey instead of adding a duplicate. Update still upserts.
+func WithUniqueKeys() IonDictionaryOption {
+	return func(conf *IonDictionaryConfigInfo) {
+		conf.uniqueKeys = true
+	}
 }
 
 type IonDictionaryParent struct {
@@ -110,6 +365,84 @@ type IonDictionaryParent struct {
 	compare  IonDictionaryCompare
 	id       IonDictionaryID
 	dictType IonDictionaryType
+	// every handler has to reject duplicate inserts when set.
+	uniqueKeys bool
+	// every handler has to hide records older than ttl when set.
+	ttl   time.Duration
+	clock func() time.Time
+	// called by handlers with every expired record they remove, before it
+	// is gone, nil if nobody has to know.
+	expired func(key IonKey, val IonValue)
+	// checked by dictGet before the handler, nil if the dictionary has none.
+	bloom *ionBloomFilter
+	// in-memory handlers have to refuse inserts past these when set.
+	memoryBudget int
+	recordBudget int
+	// handlers store values encoded by codec when set.
+	codec IonValueCodec
+}
+
+// IonMemoryUsage is what an in-memory dictionary holds right now.
+type IonMemoryUsage struct {
+	Records int
+	Bytes   int
+}
+
+// dictApplyConfig copies the handler independent options of conf to dict.
+func dictApplyConfig(dict *IonDictionary, conf *IonDictionaryConfigInfo) {
+	dict.instance.uniqueKeys = conf.uniqueKeys
+	dict.instance.ttl = conf.ttl
+	dict.instance.clock = conf.clock
+	dict.instance.memoryBudget = conf.memoryBudget
+	dict.instance.recordBudget = conf.recordBudget
+	dict.instance.codec = conf.codec
+
+	dict.instance.bloom = nil
+	if conf.bloomItems > 0 {
+		bf := newBloomFilter(conf.bloomItems, conf.bloomFPRate)
+		saved, err := readBloomFilter(bytes.NewReader(conf.bloomState))
+		if err == ErrOk && saved.m == bf.m && saved.k == bf.k {
+			dict.instance.bloom = saved
+		} else {
+			dict.instance.bloom = bf
+			dictBloomRebuild(dict)
+		}
+	}
+}
+
+// dictNow reads the clock of the dictionary records expire by.
+func dictNow(parent *IonDictionaryParent) time.Time {
+	if parent.clock == nil {
+		return time.Now()
+	}
+	return parent.clock()
+}
+
+// dictConfig returns the configuration dict can be opened again with. The
+// dictionary size is not known to the parent and is left to the caller.
+func dictConfig(dict *IonDictionary) IonDictionaryConfigInfo {
+	var conf IonDictionaryConfigInfo
+	conf.id = dict.instance.id
+	conf.kType = dict.instance.kType
+	conf.kSize = dict.instance.record.keySize
+	conf.vSize = dict.instance.record.valueSize
+	conf.dictType = dict.instance.dictType
+	conf.dictStatus = dict.status
+	conf.uniqueKeys = dict.instance.uniqueKeys
+	conf.ttl = dict.instance.ttl
+	conf.clock = dict.instance.clock
+	conf.memoryBudget = dict.instance.memoryBudget
+	conf.recordBudget = dict.instance.recordBudget
+	conf.codec = dict.instance.codec
+	if bf := dict.instance.bloom; bf != nil {
+		conf.bloomItems = bf.expectedItems
+		conf.bloomFPRate = bf.fpRate
+		var state bytes.Buffer
+		if bf.writeTo(&state) == ErrOk {
+			conf.bloomState = state.Bytes()
+		}
+	}
+	return conf
 }
 
 type IonDictionaryCompare func(firstKey IonKey, secondKey IonKey, keySize IonKeySize) int8
@@ -188,26 +521,18 @@ func dictCompareSignedValue(
 	secondKey IonKey,
 	keySize IonKeySize,
 ) int8 {
-	retVal := ionReturnValue
-	idx := uintptr(0)
+	if keySize <= 0 {
+		return 0
+	}
+	idx, step := dictMostSignificantByte(keySize)
 	firstByte := IonByte(*((*IonByte)(unsafe.Pointer(uintptr(firstKey) + idx))))
 	secondByte := IonByte(*((*IonByte)(unsafe.Pointer(uintptr(secondKey) + idx))))
-	// bigt comparison on sigend bit
-	if retVal = int8((secondByte >> 7) - (firstByte >> 7)); retVal != 0 {
+	// a set sign bit makes the key the smaller one. With equal signs the
+	// two's complement bytes order like unsigned ones.
+	if retVal := int8(secondByte>>7) - int8(firstByte>>7); retVal != 0 {
 		return retVal
 	}
-	for ; IonKeySize(idx) < keySize; idx++ {
-		firstByte = IonByte(*((*IonByte)(unsafe.Pointer(uintptr(firstKey) + idx))))
-		secondByte = IonByte(*((*IonByte)(unsafe.Pointer(uintptr(secondKey) + idx))))
-		if firstByte > secondByte {
-			retVal = 1
-			return retVal
-		} else if firstByte < secondByte {
-			retVal = -1
-			return retVal
-		}
-	}
-	return retVal
+	return dictCompareBytesFrom(firstKey, secondKey, keySize, idx, step)
 }
 
 func dictCompareUnsignedValue(
@@ -215,20 +540,36 @@ func dictCompareUnsignedValue(
 	secondKey IonKey,
 	keySize IonKeySize,
 ) int8 {
-	retVal := ionReturnValue
-	// bigt comparison on sigend bit
-	for idx := uintptr(0); IonKeySize(idx) < keySize; idx++ {
+	if keySize <= 0 {
+		return 0
+	}
+	idx, step := dictMostSignificantByte(keySize)
+	return dictCompareBytesFrom(firstKey, secondKey, keySize, idx, step)
+}
+
+// dictMostSignificantByte returns the offset of the most significant byte of
+// a numeric key in host byte order, and the step towards the least
+// significant one.
+func dictMostSignificantByte(keySize IonKeySize) (uintptr, uintptr) {
+	if hostIsBigEndian() {
+		return 0, 1
+	}
+	return uintptr(keySize - 1), ^uintptr(0)
+}
+
+// dictCompareBytesFrom compares keySize unsigned bytes of both keys, starting
+// at idx and moving by step.
+func dictCompareBytesFrom(firstKey IonKey, secondKey IonKey, keySize IonKeySize, idx uintptr, step uintptr) int8 {
+	for n := IonKeySize(0); n < keySize; n, idx = n+1, idx+step {
 		firstByte := IonByte(*((*IonByte)(unsafe.Pointer(uintptr(firstKey) + idx))))
 		secondByte := IonByte(*((*IonByte)(unsafe.Pointer(uintptr(secondKey) + idx))))
 		if firstByte > secondByte {
-			retVal = 1
-			return retVal
+			return 1
 		} else if firstByte < secondByte {
-			retVal = -1
-			return retVal
+			return -1
 		}
 	}
-	return retVal
+	return 0
 }
 
 func dictCompareCharArray(
@@ -288,6 +629,9 @@ func dictOpen(
 		fallbackConf.kSize = conf.kSize
 		fallbackConf.vSize = conf.vSize
 		fallbackConf.dictSize = 1
+		fallbackConf.uniqueKeys = conf.uniqueKeys
+		fallbackConf.ttl = conf.ttl
+		fallbackConf.clock = conf.clock
 
 		err = dictOpen(&fallbackHandler, &fallbackDict, &fallbackConf)
 		if err != ErrOk {
@@ -335,6 +679,7 @@ func dictOpen(
 	if error == ErrOk {
 		dict.status = ionDictionaryStatusOk
 		dict.instance.id = conf.id
+		dictApplyConfig(dict, conf)
 	} else {
 		dict.status = ionDictionaryStatusError
 	}
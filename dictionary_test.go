@@ -15,6 +15,8 @@ func TestCompareSigned(t *testing.T) {
 	one2 := 1
 	two := 2
 	eighty := 80
+	big := 256
+	minusBig := -256
 	tests := []struct {
 		name string
 		args args
@@ -45,6 +47,16 @@ func TestCompareSigned(t *testing.T) {
 			args: args{key1: IonKey(&eighty), key2: IonKey(&minus)},
 			want: 1,
 		},
+		{
+			name: "signed multi byte gt",
+			args: args{key1: IonKey(&big), key2: IonKey(&eighty)},
+			want: 1,
+		},
+		{
+			name: "signed negative multi byte lt",
+			args: args{key1: IonKey(&minusBig), key2: IonKey(&minus)},
+			want: -1,
+		},
 	}
 
 	for _, tt := range tests {
@@ -65,6 +77,8 @@ func TestCompareUnsigned(t *testing.T) {
 	}
 	uone := uint(1)
 	utwo := uint(2)
+	uone2 := uint(1)
+	ubig := uint(256)
 	tests := []struct {
 		name string
 		args args
@@ -80,6 +94,16 @@ func TestCompareUnsigned(t *testing.T) {
 			args: args{key1: IonKey(&utwo), key2: IonKey(&uone)},
 			want: 1,
 		},
+		{
+			name: "unsigned eq",
+			args: args{key1: IonKey(&uone), key2: IonKey(&uone2)},
+			want: 0,
+		},
+		{
+			name: "unsigned multi byte gt",
+			args: args{key1: IonKey(&ubig), key2: IonKey(&utwo)},
+			want: 1,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,198 @@
+package iondb
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// IonKeyFormatter turns a key into text for dumps. A nil formatter prints
+// numeric keys as numbers, strings quoted and char arrays in hex.
+type IonKeyFormatter func(key IonKey) string
+
+// Dump writes the structure of the skip list to w, one line per node, with
+// keys printed by format or by their key type if format is nil.
+func (sl *SkipList[K, V]) Dump(w io.Writer, format func(key K) string) IonErr {
+	return dictDump(&(sl.dict), w, slKeyFormatter(format))
+}
+
+// WriteDot writes the towers, links and duplicate chains of the skip list to
+// w as a Graphviz DOT graph.
+func (sl *SkipList[K, V]) WriteDot(w io.Writer, format func(key K) string) IonErr {
+	return dictWriteDot(&(sl.dict), w, slKeyFormatter(format))
+}
+
+func slKeyFormatter[K any](format func(key K) string) IonKeyFormatter {
+	if format == nil {
+		return nil
+	}
+	return func(key IonKey) string {
+		return format(*((*K)(key)))
+	}
+}
+
+// dictFormatKey prints key according to the key type of parent.
+func dictFormatKey(parent *IonDictionaryParent, key IonKey) string {
+	kSize := parent.record.keySize
+	switch parent.kType {
+	case KeyTypeNullTerminatedString:
+		return strconv.Quote(*(*string)(key))
+	case KeyTypeNumericSigned, KeyTypeNumericUnsigned:
+		if kSize <= 8 {
+			buf := make([]byte, 8)
+			// read the key into the low bytes of a uint64 in host order.
+			if hostIsBigEndian() {
+				memcpy(unsafe.Pointer(&buf[8-kSize]), unsafe.Pointer(key), uintptr(kSize))
+			} else {
+				memcpy(unsafe.Pointer(&buf[0]), unsafe.Pointer(key), uintptr(kSize))
+			}
+			v := *(*uint64)(unsafe.Pointer(&buf[0]))
+			if parent.kType == KeyTypeNumericUnsigned {
+				return strconv.FormatUint(v, 10)
+			}
+			shift := 64 - 8*kSize
+			return strconv.FormatInt(int64(v)<<shift>>shift, 10)
+		}
+	}
+	const hex = "0123456789abcdef"
+	var sb strings.Builder
+	sb.WriteString("0x")
+	for _, b := range unsafe.Slice((*byte)(key), kSize) {
+		sb.WriteByte(hex[b>>4])
+		sb.WriteByte(hex[b&0x0f])
+	}
+	return sb.String()
+}
+
+// dictDump writes the structure of dict to w. Handlers that can't show their
+// structure are dumped as their records in cursor order.
+func dictDump(dict *IonDictionary, w io.Writer, format IonKeyFormatter) IonErr {
+	if format == nil {
+		format = func(key IonKey) string { return dictFormatKey(dict.instance, key) }
+	}
+	err := (*(dict.handler)).dump(dict, w, format)
+	if err != ErrNotImplemented {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("dictionary type " + strconv.Itoa(int(dict.instance.dictType)) + "\n")
+	err = dictForEachRecord(dict, func(record *IonRecord) IonErr {
+		bw.WriteString("key " + format(record.key) + "\n")
+		return ErrOk
+	})
+	if err != ErrOk {
+		return err
+	}
+	if bw.Flush() != nil {
+		return ErrFileWriteError
+	}
+	return ErrOk
+}
+
+// dictWriteDot writes the structure of dict to w as a Graphviz DOT graph.
+func dictWriteDot(dict *IonDictionary, w io.Writer, format IonKeyFormatter) IonErr {
+	if format == nil {
+		format = func(key IonKey) string { return dictFormatKey(dict.instance, key) }
+	}
+	return (*(dict.handler)).writeDot(dict, w, format)
+}
+
+// slDump writes a summary line, then a line for every node with its
+// position, key, height and link widths, marking duplicates.
+func slDump(skipList *ionSkipList, w io.Writer, format IonKeyFormatter) IonErr {
+	kSize := skipList.super.record.keySize
+	bw := bufio.NewWriter(w)
+	stats := slStats(skipList)
+	bw.WriteString("skip list: " + strconv.Itoa(stats.Records) + " records, max height " + strconv.Itoa(int(skipList.maxheight)) +
+		", p " + strconv.Itoa(skipList.pnum) + "/" + strconv.Itoa(skipList.pden) + "\n")
+	bw.WriteString("levels:")
+	for _, count := range stats.Levels {
+		bw.WriteString(" " + strconv.Itoa(count))
+	}
+	bw.WriteString("\n")
+
+	pos := 0
+	prev := skipList.head
+	for node := skipList.head.next[0]; node != nil; prev, node = node, node.next[0] {
+		pos++
+		bw.WriteString(strconv.Itoa(pos) + " key " + format(node.key) + " height " + strconv.Itoa(int(node.height)+1) + " widths")
+		for _, width := range node.width {
+			bw.WriteString(" " + strconv.Itoa(width))
+		}
+		if prev != skipList.head && skipList.super.compare(prev.key, node.key, kSize) == 0 {
+			bw.WriteString(" duplicate")
+		}
+		if slExpired(skipList, node) {
+			bw.WriteString(" expired")
+		}
+		bw.WriteString("\n")
+	}
+	if bw.Flush() != nil {
+		return ErrFileWriteError
+	}
+	return ErrOk
+}
+
+// slWriteDot draws every node as a tower of its levels, left to right, with
+// an edge per link labelled by its width. Duplicates are dashed.
+func slWriteDot(skipList *ionSkipList, w io.Writer, format IonKeyFormatter) IonErr {
+	kSize := skipList.super.record.keySize
+	bw := bufio.NewWriter(w)
+	ids := map[*ionSlNode]string{skipList.head: "head"}
+
+	bw.WriteString("digraph skiplist {\n\trankdir=LR;\n\tnode [shape=record];\n")
+	bw.WriteString("\thead [label=\"" + dotTower(skipList.head, "head") + "\"];\n")
+	prev := skipList.head
+	for node := skipList.head.next[0]; node != nil; prev, node = node, node.next[0] {
+		id := "n" + strconv.Itoa(len(ids))
+		ids[node] = id
+		style := ""
+		if prev != skipList.head && skipList.super.compare(prev.key, node.key, kSize) == 0 {
+			style = ", style=dashed"
+		}
+		bw.WriteString("\t" + id + " [label=\"" + dotTower(node, dotEscape(format(node.key))) + "\"" + style + "];\n")
+	}
+	bw.WriteString("\tnil [shape=point];\n")
+
+	for node := skipList.head; node != nil; node = node.next[0] {
+		for h := len(node.next) - 1; h >= 0; h-- {
+			target := "nil"
+			if next := node.next[h]; next != nil {
+				target = ids[next] + ":l" + strconv.Itoa(h)
+			}
+			bw.WriteString("\t" + ids[node] + ":l" + strconv.Itoa(h) + " -> " + target + " [label=\"" + strconv.Itoa(node.width[h]) + "\"];\n")
+		}
+	}
+	bw.WriteString("}\n")
+	if bw.Flush() != nil {
+		return ErrFileWriteError
+	}
+	return ErrOk
+}
+
+// dotTower is the record label of a node: a field per level, top first,
+// with the name written next to level 0.
+func dotTower(node *ionSlNode, name string) string {
+	var sb strings.Builder
+	for h := len(node.next) - 1; h > 0; h-- {
+		sb.WriteString("<l" + strconv.Itoa(h) + "> |")
+	}
+	sb.WriteString("<l0> " + name)
+	return sb.String()
+}
+
+// dotEscape escapes the characters that mean something in a record label.
+func dotEscape(text string) string {
+	var sb strings.Builder
+	for _, r := range text {
+		switch r {
+		case '"', '\\', '|', '{', '}', '<', '>':
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,114 @@
+package iondb
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestDump(t *testing.T) {
+	dict := NewSkipList[int64, int64](-1, KeyTypeNumericSigned, 8, 8, 7, WithMaxHeight(1))
+	for _, key := range []int64{3, -1, 3, 7} {
+		dict.Insert(key, key)
+	}
+
+	tests := []struct {
+		name   string
+		format func(key int64) string
+		want   string
+	}{
+		{
+			name: "default formatter",
+			want: "skip list: 4 records, max height 1, p 1/4\nlevels: 4\n" +
+				"1 key -1 height 1 widths 1\n2 key 3 height 1 widths 1\n3 key 3 height 1 widths 1 duplicate\n4 key 7 height 1 widths 0\n",
+		},
+		{
+			name:   "custom formatter",
+			format: func(key int64) string { return "#" + strconv.FormatInt(key, 16) },
+			want: "skip list: 4 records, max height 1, p 1/4\nlevels: 4\n" +
+				"1 key #-1 height 1 widths 1\n2 key #3 height 1 widths 1\n3 key #3 height 1 widths 1 duplicate\n4 key #7 height 1 widths 0\n",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			if err := dict.Dump(&sb, tt.format); err != ErrOk {
+				t.Fatalf("got err = %v, want = %v", err, ErrOk)
+			}
+			if got := sb.String(); got != tt.want {
+				t.Errorf("got dump = %q, want = %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteDot(t *testing.T) {
+	str := ""
+	dict := NewSkipList[string, int](-1, KeyTypeNullTerminatedString, int(unsafe.Sizeof(str)), 8, 4, WithSeed(3))
+	keys := []string{"a", "b|c", "d", "e", "f", "g", "h", "i", "d"}
+	for i, key := range keys {
+		dict.Insert(key, i)
+	}
+	var sb strings.Builder
+	if err := dict.WriteDot(&sb, nil); err != ErrOk {
+		t.Fatalf("got err = %v, want = %v", err, ErrOk)
+	}
+	got := sb.String()
+
+	skipList := (*ionSkipList)(unsafe.Pointer(dict.dict.instance))
+	links := 0
+	for node := skipList.head; node != nil; node = node.next[0] {
+		links += len(node.next)
+	}
+	tests := []struct {
+		name  string
+		check func(dot string) bool
+	}{
+		{name: "graph", check: func(dot string) bool {
+			return strings.HasPrefix(dot, "digraph skiplist {\n") && strings.HasSuffix(dot, "}\n")
+		}},
+		{name: "an edge per link", check: func(dot string) bool { return strings.Count(dot, " -> ") == links }},
+		{name: "escaped key", check: func(dot string) bool { return strings.Contains(dot, `\"b\|c\"`) }},
+		{name: "dashed duplicate", check: func(dot string) bool { return strings.Count(dot, "style=dashed") == 1 }},
+		{name: "a node per record", check: func(dot string) bool { return strings.Count(dot, "[label=\"<") == len(keys)+1 }},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.check(got) {
+				t.Errorf("got dot = %v", got)
+			}
+		})
+	}
+}
+
+func TestFormatKey(t *testing.T) {
+	str := "a\"b"
+	signed := int16(-300)
+	unsigned := uint32(4000000000)
+	chars := [3]byte{0x01, 0xab, 0xff}
+	tests := []struct {
+		name  string
+		kType IonKeyType
+		kSize IonKeySize
+		key   IonKey
+		want  string
+	}{
+		{name: "string", kType: KeyTypeNullTerminatedString, kSize: int(unsafe.Sizeof(str)), key: IonKey(unsafe.Pointer(&str)), want: `"a\"b"`},
+		{name: "signed", kType: KeyTypeNumericSigned, kSize: 2, key: IonKey(unsafe.Pointer(&signed)), want: "-300"},
+		{name: "unsigned", kType: KeyTypeNumericUnsigned, kSize: 4, key: IonKey(unsafe.Pointer(&unsigned)), want: "4000000000"},
+		{name: "char array", kType: KeyTypeCharArray, kSize: 3, key: IonKey(unsafe.Pointer(&chars[0])), want: "0x01abff"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			parent := &IonDictionaryParent{kType: tt.kType}
+			parent.record.keySize = tt.kSize
+			if got := dictFormatKey(parent, tt.key); got != tt.want {
+				t.Errorf("got key = %v, want = %v", got, tt.want)
+			}
+		})
+	}
+}
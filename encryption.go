@@ -0,0 +1,235 @@
+package iondb
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	bpNonceSize = 12
+	bpTagSize   = 16
+	// bpSealOverhead is what an encrypted page gives up: its nonce and tag.
+	bpSealOverhead = bpNonceSize + bpTagSize
+)
+
+// bpJournalPage is the page after the header of an encrypted file, which
+// holds the last page RotateKey re-sealed.
+const bpJournalPage = -1
+
+// EnableEncryption makes the pool seal every page it writes with AES-GCM
+// under the key of its file and open every page it reads, so pages are
+// unreadable at rest and tampered, truncated or swapped pages are reported
+// as ErrCorruptedData. It must be called before the first page is read,
+// and every file must be given its key with SetKey before it is used. A
+// pool whose pages would leave less than bpCountSize bytes once the nonce,
+// tag and any checksum are taken gives ErrInvalidiInitialSize.
+func (bp *BufferPool) EnableEncryption() IonErr {
+	bp.encrypted = true
+	if bp.PageSize() < bpCountSize {
+		bp.encrypted = false
+		return ErrInvalidiInitialSize
+	}
+	bp.keys = make(map[IonFile]cipher.AEAD)
+	if bp.written == nil {
+		bp.written = make(map[IonFile]int64)
+	}
+	bp.sealBuf = make([]byte, bp.pageSize)
+	return ErrOk
+}
+
+// bpNewAEAD returns AES-GCM under key, which must be 16, 24 or 32 bytes.
+func bpNewAEAD(key []byte) (cipher.AEAD, IonErr) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, ErrOutOfBounds
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, ErrOutOfBounds
+	}
+	return aead, ErrOk
+}
+
+// SetKey gives the pool the key the pages of file are encrypted with and
+// reads the header of the file, or writes one if the file is new. A
+// file-backed handler calls it when it opens the file, with the key its
+// caller supplied. A header that does not open under key, or is missing
+// from a file with pages, gives ErrCorruptedData, unless RotateKey left it
+// under key in the journal. The key is forgotten when the file is dropped.
+func (bp *BufferPool) SetKey(file IonFile, key []byte) IonErr {
+	if !bp.encrypted {
+		return ErrUninitialized
+	}
+	aead, err := bpNewAEAD(key)
+	if err != ErrOk {
+		return err
+	}
+	if err := bp.readHeader(file, aead); err != ErrOk {
+		return err
+	}
+	bp.keys[file] = aead
+	return ErrOk
+}
+
+// writeJournaled writes page, sealed as page pageNo, to the journal of file
+// and then in place, so a write torn by a crash can be redone.
+func (bp *BufferPool) writeJournaled(file IonFile, pageNo int64, page []byte) IonErr {
+	if _, err := file.WriteAt(page, bp.offset(bpJournalPage)); err != nil {
+		return ErrFileWriteError
+	}
+	if _, err := file.WriteAt(page, bp.offset(pageNo)); err != nil {
+		return ErrFileWriteError
+	}
+	return ErrOk
+}
+
+// readJournal reads the journal of file into page if it holds page pageNo
+// sealed under aead, and gives ErrCorruptedData if it does not.
+func (bp *BufferPool) readJournal(file IonFile, aead cipher.AEAD, pageNo int64, page []byte) IonErr {
+	n, err := file.ReadAt(page, bp.offset(bpJournalPage))
+	if err != nil && err != io.EOF {
+		return ErrFileReadError
+	}
+	plain := make([]byte, bp.plainSize())
+	if n < len(page) || bpOpenPage(aead, pageNo, page, plain) != ErrOk {
+		return ErrCorruptedData
+	}
+	return ErrOk
+}
+
+// bpPageAAD is the data a page is authenticated with besides its contents:
+// its number, so a page copied over another, or over the header, does not
+// open.
+func bpPageAAD(pageNo int64) []byte {
+	return binary.LittleEndian.AppendUint64(nil, uint64(pageNo))
+}
+
+// bpSealPage writes plain, sealed as page pageNo, to page as its nonce
+// followed by the ciphertext and tag.
+func bpSealPage(aead cipher.AEAD, pageNo int64, plain []byte, page []byte) IonErr {
+	nonce := page[:bpNonceSize]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return ErrFileWriteError
+	}
+	aead.Seal(page[bpNonceSize:bpNonceSize], nonce, plain, bpPageAAD(pageNo))
+	return ErrOk
+}
+
+// bpOpenPage opens page, sealed as page pageNo, into plain.
+func bpOpenPage(aead cipher.AEAD, pageNo int64, page []byte, plain []byte) IonErr {
+	plain = plain[:len(page)-bpSealOverhead]
+	if _, err := aead.Open(plain[:0], page[:bpNonceSize], page[bpNonceSize:], bpPageAAD(pageNo)); err != nil {
+		return ErrCorruptedData
+	}
+	return ErrOk
+}
+
+func bpZeroPage(page []byte) bool {
+	for _, b := range page {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (bp *BufferPool) seal(file IonFile, pageNo int64, plain []byte, page []byte) IonErr {
+	aead, ok := bp.keys[file]
+	if !ok {
+		return ErrUninitialized
+	}
+	return bpSealPage(aead, pageNo, plain, page)
+}
+
+func (bp *BufferPool) open(file IonFile, pageNo int64, page []byte, plain []byte) IonErr {
+	aead, ok := bp.keys[file]
+	if !ok {
+		return ErrUninitialized
+	}
+	return bpOpenPage(aead, pageNo, page, plain)
+}
+
+// RotateKey re-encrypts every page of file in place under newKey and makes
+// it the key of the file. Cached pages are written back first. Every page
+// is written to the journal of the file before it is written in place, so
+// a rotation that is interrupted, by a failed write or a crash, can be
+// finished by setting the old key again and calling RotateKey with the same
+// new key: pages already sealed under the new key are left as they are and
+// a page torn mid-write is taken from the journal. The header is re-sealed
+// last; if the old key no longer opens it, setting the new key finishes
+// the rotation.
+func (bp *BufferPool) RotateKey(file IonFile, newKey []byte) IonErr {
+	if !bp.encrypted {
+		return ErrUninitialized
+	}
+	oldAEAD, ok := bp.keys[file]
+	if !ok {
+		return ErrUninitialized
+	}
+	newAEAD, err := bpNewAEAD(newKey)
+	if err != ErrOk {
+		return err
+	}
+	if err := bp.Flush(file); err != ErrOk {
+		return err
+	}
+
+	written := bp.written[file]
+	page := make([]byte, bp.pageSize)
+	plain := make([]byte, bp.plainSize())
+	for pageNo := int64(0); ; pageNo++ {
+		n, err := file.ReadAt(page, bp.offset(pageNo))
+		if err != nil && err != io.EOF {
+			return ErrFileReadError
+		}
+		if n == 0 && pageNo >= written {
+			break
+		}
+		for i := n; i < len(page); i++ {
+			page[i] = 0
+		}
+		if bpZeroPage(page) {
+			if pageNo < written {
+				return ErrCorruptedData
+			}
+			continue
+		}
+		if bpOpenPage(oldAEAD, pageNo, page, plain) != ErrOk {
+			// sealed under the new key by a rotation that was interrupted,
+			// or torn by one and still in the journal.
+			if bpOpenPage(newAEAD, pageNo, page, plain) == ErrOk {
+				continue
+			}
+			if err := bp.readJournal(file, newAEAD, pageNo, page); err != ErrOk {
+				return err
+			}
+			if _, err := file.WriteAt(page, bp.offset(pageNo)); err != nil {
+				return ErrFileWriteError
+			}
+			continue
+		}
+		if err := bpSealPage(newAEAD, pageNo, plain, page); err != ErrOk {
+			return err
+		}
+		if err := bp.writeJournaled(file, pageNo, page); err != ErrOk {
+			return err
+		}
+	}
+	// the header goes last, so an interrupted rotation still opens under
+	// the old key.
+	header, err := bp.sealHeader(newAEAD, written)
+	if err != ErrOk {
+		return err
+	}
+	if err := bp.writeJournaled(file, bp.headerPage(), header); err != ErrOk {
+		return err
+	}
+	if _, err := file.WriteAt(make([]byte, bp.pageSize), bp.offset(bpJournalPage)); err != nil {
+		return ErrFileWriteError
+	}
+	bp.keys[file] = newAEAD
+	return ErrOk
+}
@@ -0,0 +1,409 @@
+package iondb
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestBufferPoolEncryption(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 16)
+	// at is where page pageNo starts, past the header and journal.
+	at := func(pageNo int) int { return (pageNo + 2) * 64 }
+	// four written pages of 64 bytes, 36 of them data.
+	written := func() *memFile {
+		file := new(memFile)
+		bp, _ := NewBufferPool(64, 4, EvictionLRU)
+		bp.EnableEncryption()
+		bp.SetKey(file, key)
+		for pageNo := int64(0); pageNo < 4; pageNo++ {
+			page, _ := bp.Pin(file, pageNo)
+			for i := range page.Data {
+				page.Data[i] = byte(pageNo + 1)
+			}
+			bp.Unpin(page, true)
+		}
+		bp.Flush(file)
+		return file
+	}
+
+	tests := []struct {
+		name    string
+		key     []byte
+		corrupt func(file *memFile)
+		setKey  IonErr
+		bad     []int64
+	}{
+		{name: "intact", corrupt: func(file *memFile) {}},
+		{name: "flipped ciphertext", corrupt: func(file *memFile) { file.data[at(1)+20] ^= 0x10 }, bad: []int64{1}},
+		{name: "flipped nonce", corrupt: func(file *memFile) { file.data[at(2)+3] ^= 1 }, bad: []int64{2}},
+		{name: "flipped tag", corrupt: func(file *memFile) { file.data[at(4)-1]++ }, bad: []int64{3}},
+		{name: "swapped pages", corrupt: func(file *memFile) {
+			page := append([]byte(nil), file.data[at(0):at(1)]...)
+			copy(file.data[at(0):at(1)], file.data[at(1):at(2)])
+			copy(file.data[at(1):at(2)], page)
+		}, bad: []int64{0, 1}},
+		{name: "torn last page", corrupt: func(file *memFile) { file.data = file.data[:at(3)+30] }, bad: []int64{3}},
+		{name: "truncated", corrupt: func(file *memFile) { file.data = file.data[:at(2)] }, bad: []int64{2, 3}},
+		{name: "zeroed page", corrupt: func(file *memFile) {
+			for i := at(2); i < at(3); i++ {
+				file.data[i] = 0
+			}
+		}, bad: []int64{2}},
+		{name: "zeroed header", corrupt: func(file *memFile) {
+			for i := 0; i < at(0); i++ {
+				file.data[i] = 0
+			}
+		}, setKey: ErrCorruptedData},
+		{name: "page over header", corrupt: func(file *memFile) {
+			copy(file.data[:at(0)], file.data[at(0):at(1)])
+		}, setKey: ErrCorruptedData},
+		{name: "wrong key", key: bytes.Repeat([]byte{8}, 16), corrupt: func(file *memFile) {}, setKey: ErrCorruptedData},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			file := written()
+			if bytes.Contains(file.data, bytes.Repeat([]byte{2}, 8)) {
+				t.Fatalf("got plain data in the file")
+			}
+			tt.corrupt(file)
+			bp, _ := NewBufferPool(64, 2, EvictionLRU)
+			bp.EnableEncryption()
+			if tt.key == nil {
+				tt.key = key
+			}
+			if err := bp.SetKey(file, tt.key); err != tt.setKey {
+				t.Fatalf("got setKey err = %v, want = %v", err, tt.setKey)
+			}
+			if tt.setKey != ErrOk {
+				return
+			}
+			if got := bp.PageSize(); got != 36 {
+				t.Errorf("got page size = %v, want = %v", got, 36)
+			}
+
+			bad, err := bp.Verify(file)
+			if err != ErrOk {
+				t.Fatalf("got err = %v, want = %v", err, ErrOk)
+			}
+			if len(bad) != len(tt.bad) {
+				t.Fatalf("got bad pages = %v, want = %v", bad, tt.bad)
+			}
+			for i := range bad {
+				if bad[i] != tt.bad[i] {
+					t.Errorf("got bad pages = %v, want = %v", bad, tt.bad)
+				}
+			}
+
+			isBad := make(map[int64]bool)
+			for _, pageNo := range tt.bad {
+				isBad[pageNo] = true
+			}
+			for pageNo := int64(0); pageNo < 4; pageNo++ {
+				page, err := bp.Pin(file, pageNo)
+				if isBad[pageNo] {
+					if err != ErrCorruptedData {
+						t.Errorf("got err = %v for page %v, want = %v", err, pageNo, ErrCorruptedData)
+					}
+					continue
+				}
+				if err != ErrOk {
+					t.Fatalf("got err = %v for page %v, want = %v", err, pageNo, ErrOk)
+				}
+				want := byte(pageNo + 1)
+				if !bytes.Equal(page.Data, bytes.Repeat([]byte{want}, 36)) {
+					t.Errorf("got page %v = %v, want all %v", pageNo, page.Data, want)
+				}
+				bp.Unpin(page, false)
+			}
+			if got := bp.Stats().Corruptions; got != len(tt.bad) {
+				t.Errorf("got corruptions = %v, want = %v", got, len(tt.bad))
+			}
+		})
+	}
+}
+
+func TestBufferPoolEncryptionSparse(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 16)
+	file := new(memFile)
+	bp, _ := NewBufferPool(64, 2, EvictionLRU)
+	bp.EnableEncryption()
+	bp.SetKey(file, key)
+	page, _ := bp.Pin(file, 3)
+	page.Data[0] = 3
+	bp.Unpin(page, true)
+	bp.Flush(file)
+
+	// the pages skipped over read as zeros, not as zeroed pages.
+	for pageNo := int64(0); pageNo < 4; pageNo++ {
+		page, err := bp.Pin(file, pageNo)
+		if err != ErrOk {
+			t.Fatalf("got err = %v for page %v, want = %v", err, pageNo, ErrOk)
+		}
+		want := byte(0)
+		if pageNo == 3 {
+			want = 3
+		}
+		if page.Data[0] != want {
+			t.Errorf("got page %v byte = %v, want = %v", pageNo, page.Data[0], want)
+		}
+		bp.Unpin(page, false)
+	}
+	if bad, err := bp.Verify(file); err != ErrOk || len(bad) != 0 {
+		t.Errorf("got verify = %v, %v, want no bad pages", bad, err)
+	}
+	if err := bp.RotateKey(file, bytes.Repeat([]byte{8}, 16)); err != ErrOk {
+		t.Fatalf("got rotate err = %v, want = %v", err, ErrOk)
+	}
+
+	// a skipped page zeroed afterwards is still caught.
+	for i := 0; i < 64; i++ {
+		file.data[(1+2)*64+i] = 0
+	}
+	if bad, _ := bp.Verify(file); len(bad) != 1 || bad[0] != 1 {
+		t.Errorf("got bad pages = %v, want = %v", bad, []int64{1})
+	}
+}
+
+func TestBufferPoolEncryptionChecksums(t *testing.T) {
+	file := new(memFile)
+	bp, _ := NewBufferPool(64, 2, EvictionLRU)
+	bp.EnableChecksums()
+	bp.EnableEncryption()
+	bp.SetKey(file, bytes.Repeat([]byte{1}, 32))
+	if got := bp.PageSize(); got != 32 {
+		t.Errorf("got page size = %v, want = %v", got, 32)
+	}
+	for pageNo := int64(0); pageNo < 3; pageNo++ {
+		page, _ := bp.Pin(file, pageNo)
+		copy(page.Data, "page")
+		page.Data[4] = byte(pageNo)
+		bp.Unpin(page, true)
+	}
+	if err := bp.Drop(file); err != ErrOk {
+		t.Fatalf("got drop err = %v, want = %v", err, ErrOk)
+	}
+	if _, err := bp.Pin(file, 0); err != ErrUninitialized {
+		t.Errorf("got pin without key err = %v, want = %v", err, ErrUninitialized)
+	}
+	bp.SetKey(file, bytes.Repeat([]byte{1}, 32))
+	for pageNo := int64(0); pageNo < 3; pageNo++ {
+		page, err := bp.Pin(file, pageNo)
+		if err != ErrOk || string(page.Data[:4]) != "page" || page.Data[4] != byte(pageNo) {
+			t.Fatalf("got page %v = %v, %v", pageNo, page, err)
+		}
+		bp.Unpin(page, false)
+	}
+	if bad, err := bp.Verify(file); err != ErrOk || len(bad) != 0 {
+		t.Errorf("got verify = %v, %v, want no bad pages", bad, err)
+	}
+}
+
+func TestBufferPoolSetKey(t *testing.T) {
+	file := new(memFile)
+	plain, _ := NewBufferPool(64, 2, EvictionLRU)
+	if err := plain.SetKey(file, make([]byte, 16)); err != ErrUninitialized {
+		t.Errorf("got err = %v, want = %v", err, ErrUninitialized)
+	}
+	if err := plain.RotateKey(file, make([]byte, 16)); err != ErrUninitialized {
+		t.Errorf("got rotate err = %v, want = %v", err, ErrUninitialized)
+	}
+
+	tests := []struct {
+		size int
+		want IonErr
+	}{
+		{size: 0, want: ErrOutOfBounds},
+		{size: 15, want: ErrOutOfBounds},
+		{size: 16, want: ErrOk},
+		{size: 24, want: ErrOk},
+		{size: 32, want: ErrOk},
+		{size: 64, want: ErrOutOfBounds},
+	}
+	for _, tt := range tests {
+		file := new(memFile)
+		bp, _ := NewBufferPool(64, 2, EvictionLRU)
+		bp.EnableEncryption()
+		if got := bp.SetKey(file, make([]byte, tt.size)); got != tt.want {
+			t.Errorf("got err = %v for a key of %v bytes, want = %v", got, tt.size, tt.want)
+		}
+	}
+}
+
+func TestBufferPoolEnableEncryption(t *testing.T) {
+	tests := []struct {
+		name      string
+		pageSize  int
+		checksums bool
+		want      IonErr
+	}{
+		{name: "smaller than the seal", pageSize: 16, want: ErrInvalidiInitialSize},
+		{name: "no room for the count", pageSize: 35, want: ErrInvalidiInitialSize},
+		{name: "room for the count", pageSize: 36, want: ErrOk},
+		{name: "no room for the checksum", pageSize: 36, checksums: true, want: ErrInvalidiInitialSize},
+		{name: "room for the checksum", pageSize: 40, checksums: true, want: ErrOk},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			bp, _ := NewBufferPool(tt.pageSize, 2, EvictionLRU)
+			if tt.checksums {
+				bp.EnableChecksums()
+			}
+			if err := bp.EnableEncryption(); err != tt.want {
+				t.Fatalf("got err = %v, want = %v", err, tt.want)
+			}
+			if err := bp.SetKey(new(memFile), make([]byte, 16)); tt.want != ErrOk && err != ErrUninitialized {
+				t.Errorf("got setKey err = %v, want = %v", err, ErrUninitialized)
+			}
+		})
+	}
+}
+
+func TestBufferPoolRotateKey(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 16)
+	newKey := bytes.Repeat([]byte{2}, 16)
+	file := new(memFile)
+	bp, _ := NewBufferPool(64, 2, EvictionLRU)
+	bp.EnableEncryption()
+	bp.SetKey(file, oldKey)
+	for pageNo := int64(0); pageNo < 5; pageNo++ {
+		page, _ := bp.Pin(file, pageNo)
+		page.Data[0] = byte(10 + pageNo)
+		bp.Unpin(page, true)
+	}
+
+	// the dirty pages still cached are rotated with the rest.
+	if err := bp.RotateKey(file, newKey); err != ErrOk {
+		t.Fatalf("got err = %v, want = %v", err, ErrOk)
+	}
+	if err := bp.RotateKey(file, make([]byte, 5)); err != ErrOutOfBounds {
+		t.Errorf("got err = %v for a bad key, want = %v", err, ErrOutOfBounds)
+	}
+	check := func(key []byte) {
+		t.Helper()
+		reader, _ := NewBufferPool(64, 2, EvictionLRU)
+		reader.EnableEncryption()
+		reader.SetKey(file, key)
+		for pageNo := int64(0); pageNo < 5; pageNo++ {
+			page, err := reader.Pin(file, pageNo)
+			if err != ErrOk || page.Data[0] != byte(10+pageNo) {
+				t.Fatalf("got page %v = %v, %v", pageNo, page, err)
+			}
+			reader.Unpin(page, false)
+		}
+	}
+	check(newKey)
+	verifyUnder := func(file *memFile, key []byte) ([]int64, IonErr) {
+		bp, _ := NewBufferPool(64, 2, EvictionLRU)
+		bp.EnableEncryption()
+		bp.SetKey(file, key)
+		return bp.Verify(file)
+	}
+	if bad, _ := bp.Verify(file); len(bad) != 0 {
+		t.Errorf("got bad pages = %v after rotation, want none", bad)
+	}
+	stale, _ := NewBufferPool(64, 2, EvictionLRU)
+	stale.EnableEncryption()
+	if err := stale.SetKey(file, oldKey); err != ErrCorruptedData {
+		t.Errorf("got setKey err = %v under the old key, want = %v", err, ErrCorruptedData)
+	}
+
+	// a rotation cut short by a failed write after two pages, each written
+	// to the journal and in place, is finished by running it again.
+	thirdKey := bytes.Repeat([]byte{3}, 16)
+	cut := &failingFile{memFile: file, writes: 4}
+	half, _ := NewBufferPool(64, 2, EvictionLRU)
+	half.EnableEncryption()
+	half.SetKey(cut, newKey)
+	if err := half.RotateKey(cut, thirdKey); err != ErrFileWriteError {
+		t.Fatalf("got partial err = %v, want = %v", err, ErrFileWriteError)
+	}
+	if bad, _ := verifyUnder(file, newKey); len(bad) != 2 {
+		t.Fatalf("got bad pages = %v under the new key, want the first 2", bad)
+	}
+	resumed, _ := NewBufferPool(64, 2, EvictionLRU)
+	resumed.EnableEncryption()
+	resumed.SetKey(file, newKey)
+	if err := resumed.RotateKey(file, thirdKey); err != ErrOk {
+		t.Fatalf("got resumed err = %v, want = %v", err, ErrOk)
+	}
+	check(thirdKey)
+
+	// pages under neither key stop the rotation.
+	file.data[(1+2)*64+20] ^= 1
+	if err := resumed.RotateKey(file, oldKey); err != ErrCorruptedData {
+		t.Errorf("got err = %v for a tampered page, want = %v", err, ErrCorruptedData)
+	}
+}
+
+func TestBufferPoolRotateKeyTorn(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 16)
+	newKey := bytes.Repeat([]byte{2}, 16)
+	file := new(memFile)
+	bp, _ := NewBufferPool(64, 2, EvictionLRU)
+	bp.EnableEncryption()
+	bp.SetKey(file, oldKey)
+	for pageNo := int64(0); pageNo < 3; pageNo++ {
+		page, _ := bp.Pin(file, pageNo)
+		page.Data[0] = byte(10 + pageNo)
+		bp.Unpin(page, true)
+	}
+	bp.Flush(file)
+
+	// tear every write of the rotation in turn and finish it after.
+	for writes := 0; ; writes++ {
+		crashed := &failingFile{memFile: &memFile{data: append([]byte(nil), file.data...)}, writes: writes, torn: true}
+		half, _ := NewBufferPool(64, 2, EvictionLRU)
+		half.EnableEncryption()
+		half.SetKey(crashed, oldKey)
+		if half.RotateKey(crashed, newKey) == ErrOk {
+			break
+		}
+
+		resumed, _ := NewBufferPool(64, 2, EvictionLRU)
+		resumed.EnableEncryption()
+		if err := resumed.SetKey(crashed.memFile, oldKey); err == ErrOk {
+			if err := resumed.RotateKey(crashed.memFile, newKey); err != ErrOk {
+				t.Fatalf("got resumed err = %v after %v writes, want = %v", err, writes, ErrOk)
+			}
+		}
+
+		reader, _ := NewBufferPool(64, 2, EvictionLRU)
+		reader.EnableEncryption()
+		if err := reader.SetKey(crashed.memFile, newKey); err != ErrOk {
+			t.Fatalf("got setKey err = %v after %v writes, want = %v", err, writes, ErrOk)
+		}
+		if bad, err := reader.Verify(crashed.memFile); err != ErrOk || len(bad) != 0 {
+			t.Errorf("got verify = %v, %v after %v writes, want no bad pages", bad, err, writes)
+		}
+		for pageNo := int64(0); pageNo < 3; pageNo++ {
+			page, err := reader.Pin(crashed.memFile, pageNo)
+			if err != ErrOk || page.Data[0] != byte(10+pageNo) {
+				t.Fatalf("got page %v = %v, %v after %v writes", pageNo, page, err, writes)
+			}
+			reader.Unpin(page, false)
+		}
+	}
+}
+
+// failingFile fails every write after its first writes. A torn file
+// writes half of the write it fails, as a crash mid-write would.
+type failingFile struct {
+	*memFile
+	writes int
+	torn   bool
+}
+
+func (f *failingFile) WriteAt(p []byte, off int64) (int, error) {
+	if f.writes == 0 {
+		if f.torn {
+			f.memFile.WriteAt(p[:len(p)/2], off)
+			f.torn = false
+		}
+		return 0, io.ErrShortWrite
+	}
+	f.writes--
+	return f.memFile.WriteAt(p, off)
+}
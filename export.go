@@ -0,0 +1,396 @@
+package iondb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash"
+	"hash/crc32"
+	"io"
+	"unsafe"
+)
+
+// Export format, all integers little-endian:
+//
+//	header:  magic "IONX" | version u8 | dictType u8 | kType u8 | flags u8 | kSize u32 | vSize u32
+//	bloom:   tag u8 = 2 | size u32 | filter, only from a dictionary with a Bloom filter
+//	record:  tag u8 = 1 | keyLen u32 | key | valLen u32 | val
+//	trailer: tag u8 = 0 | record count u64 | crc32 (IEEE) of every preceding byte
+//
+// Numeric keys are written little-endian whatever the host byte order is.
+// Null terminated string keys are written as their contents, at most
+// exportMaxKeyLen bytes of them. Values are written as their raw valueSize
+// bytes, so values, and keys other than strings, that hold pointers can't
+// be exported. Version 1 streams have no bloom section and are still read.
+const (
+	exportVersion    = 2
+	exportHeaderSize = 16
+	exportTagRecord  = 1
+	exportTagEnd     = 0
+	exportTagBloom   = 2
+	exportMaxKeyLen  = 1 << 16
+
+	exportFlagUniqueKeys = 1 << 0
+)
+
+var exportMagic = [4]byte{'I', 'O', 'N', 'X'}
+
+// IonExportHeader describes the dictionary an export stream was taken from.
+type IonExportHeader struct {
+	Version  uint8
+	DictType IonDictionaryType
+	KeyType  IonKeyType
+	KeySize  IonKeySize
+	ValSize  IonValueSize
+	// UniqueKeys is set when the dictionary rejected duplicate keys.
+	UniqueKeys bool
+}
+
+func hostIsBigEndian() bool {
+	one := uint16(1)
+	return *(*byte)(unsafe.Pointer(&one)) == 0
+}
+
+// exportKeyBytes returns the portable encoding of key.
+func exportKeyBytes(kType IonKeyType, key IonKey, kSize IonKeySize) []byte {
+	if kType == KeyTypeNullTerminatedString {
+		return []byte(*(*string)(key))
+	}
+	buf := make([]byte, kSize)
+	memcpy(unsafe.Pointer(&buf[0]), unsafe.Pointer(key), uintptr(kSize))
+	if hostIsBigEndian() && (kType == KeyTypeNumericSigned || kType == KeyTypeNumericUnsigned) {
+		reverseBytes(buf)
+	}
+	return buf
+}
+
+// importKey turns the portable encoding of a key back into an IonKey.
+func importKey(kType IonKeyType, buf []byte) IonKey {
+	if kType == KeyTypeNullTerminatedString {
+		str := string(buf)
+		return IonKey(unsafe.Pointer(&str))
+	}
+	if hostIsBigEndian() && (kType == KeyTypeNumericSigned || kType == KeyTypeNumericUnsigned) {
+		reverseBytes(buf)
+	}
+	return IonKey(unsafe.Pointer(&buf[0]))
+}
+
+func reverseBytes(buf []byte) {
+	for i, j := 0, len(buf)-1; i < j; i, j = i+1, j-1 {
+		buf[i], buf[j] = buf[j], buf[i]
+	}
+}
+
+// dictForEachRecord runs fn on every record of dict in cursor order and stops
+// at the first error fn returns.
+func dictForEachRecord(dict *IonDictionary, fn func(record *IonRecord) IonErr) IonErr {
+	predicate := new(IonPredicateAllRecords)
+	var cursor *IonDictCursor
+	var record IonRecord
+
+	err := dictFind(dict, predicate, &cursor)
+	if err != ErrOk {
+		return err
+	}
+
+	record.key = IonKey(alloc(uintptr(dict.instance.record.keySize), nil))
+	record.value = IonValue(alloc(uintptr(dict.instance.record.valueSize), nil))
+
+	cursorStatus := cursor.next(cursor, &record)
+	for ; cursorStatus == csCursorActive || cursorStatus == csCursorInitialized; cursorStatus = cursor.next(cursor, &record) {
+		if err = fn(&record); err != ErrOk {
+			cursor.destroy(&cursor)
+			return err
+		}
+	}
+	cursor.destroy(&cursor)
+
+	if cursorStatus != csEndOfResults && cursorStatus != csCursorUninitialized {
+		return ErrUninitialized
+	}
+	return ErrOk
+}
+
+type exportWriter struct {
+	w   io.Writer
+	crc hash.Hash32
+	buf [8]byte
+	err IonErr
+}
+
+func (ew *exportWriter) write(p []byte) {
+	if ew.err != ErrOk {
+		return
+	}
+	if _, err := ew.w.Write(p); err != nil {
+		ew.err = ErrFileWriteError
+		return
+	}
+	ew.crc.Write(p)
+}
+
+func (ew *exportWriter) writeU8(v uint8) {
+	ew.buf[0] = v
+	ew.write(ew.buf[:1])
+}
+
+func (ew *exportWriter) writeU32(v uint32) {
+	binary.LittleEndian.PutUint32(ew.buf[:4], v)
+	ew.write(ew.buf[:4])
+}
+
+func (ew *exportWriter) writeU64(v uint64) {
+	binary.LittleEndian.PutUint64(ew.buf[:8], v)
+	ew.write(ew.buf[:8])
+}
+
+// dictExport writes every record of dict to w in the export format.
+func dictExport(dict *IonDictionary, w io.Writer) IonErr {
+	parent := dict.instance
+	kSize := parent.record.keySize
+	vSize := parent.record.valueSize
+	ew := exportWriter{w: w, crc: crc32.NewIEEE(), err: ErrOk}
+
+	ew.write(exportMagic[:])
+	ew.writeU8(exportVersion)
+	ew.writeU8(uint8(parent.dictType))
+	ew.writeU8(uint8(parent.kType))
+	flags := uint8(0)
+	if parent.uniqueKeys {
+		flags |= exportFlagUniqueKeys
+	}
+	ew.writeU8(flags)
+	ew.writeU32(uint32(kSize))
+	ew.writeU32(uint32(vSize))
+	if bf := parent.bloom; bf != nil {
+		var filter bytes.Buffer
+		if err := bf.writeTo(&filter); err != ErrOk {
+			return err
+		}
+		ew.writeU8(exportTagBloom)
+		ew.writeU32(uint32(filter.Len()))
+		ew.write(filter.Bytes())
+	}
+
+	var count uint64
+	err := dictForEachRecord(dict, func(record *IonRecord) IonErr {
+		key := exportKeyBytes(parent.kType, record.key, kSize)
+		if len(key) > exportMaxKeyLen {
+			return ErrOutOfBounds
+		}
+		ew.writeU8(exportTagRecord)
+		ew.writeU32(uint32(len(key)))
+		ew.write(key)
+		ew.writeU32(uint32(vSize))
+		if vSize > 0 {
+			ew.write(unsafe.Slice((*byte)(record.value), vSize))
+		}
+		count++
+		return ew.err
+	})
+	if err != ErrOk {
+		return err
+	}
+
+	ew.writeU8(exportTagEnd)
+	ew.writeU64(count)
+	if ew.err != ErrOk {
+		return ew.err
+	}
+	binary.LittleEndian.PutUint32(ew.buf[:4], ew.crc.Sum32())
+	if _, err := w.Write(ew.buf[:4]); err != nil {
+		return ErrFileWriteError
+	}
+	return ErrOk
+}
+
+type exportReader struct {
+	r   io.Reader
+	crc hash.Hash32
+	buf [8]byte
+}
+
+func (er *exportReader) read(p []byte) IonErr {
+	if _, err := io.ReadFull(er.r, p); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrFileHitEof
+		}
+		return ErrFileReadError
+	}
+	er.crc.Write(p)
+	return ErrOk
+}
+
+// skip reads n bytes past, into the checksum only.
+func (er *exportReader) skip(n int64) IonErr {
+	if _, err := io.CopyN(er.crc, er.r, n); err != nil {
+		if err == io.EOF {
+			return ErrFileHitEof
+		}
+		return ErrFileReadError
+	}
+	return ErrOk
+}
+
+func (er *exportReader) readU8() (uint8, IonErr) {
+	err := er.read(er.buf[:1])
+	return er.buf[0], err
+}
+
+func (er *exportReader) readU32() (uint32, IonErr) {
+	err := er.read(er.buf[:4])
+	return binary.LittleEndian.Uint32(er.buf[:4]), err
+}
+
+func (er *exportReader) readU64() (uint64, IonErr) {
+	err := er.read(er.buf[:8])
+	return binary.LittleEndian.Uint64(er.buf[:8]), err
+}
+
+func (er *exportReader) readHeader() (IonExportHeader, IonErr) {
+	var header IonExportHeader
+	raw := make([]byte, exportHeaderSize)
+	if err := er.read(raw); err != ErrOk {
+		return header, err
+	}
+	if raw[0] != exportMagic[0] || raw[1] != exportMagic[1] || raw[2] != exportMagic[2] || raw[3] != exportMagic[3] {
+		return header, ErrUnableToConvert
+	}
+	header.Version = raw[4]
+	if header.Version < 1 || header.Version > exportVersion {
+		return header, ErrUnableToConvert
+	}
+	header.DictType = IonDictionaryType(raw[5])
+	header.KeyType = IonKeyType(raw[6])
+	header.UniqueKeys = raw[7]&exportFlagUniqueKeys != 0
+	header.KeySize = IonKeySize(binary.LittleEndian.Uint32(raw[8:12]))
+	header.ValSize = IonValueSize(binary.LittleEndian.Uint32(raw[12:16]))
+	return header, ErrOk
+}
+
+// ReadExportHeader reads only the header of an export stream. Import reads
+// the header again, so a caller sizing a new dictionary from it has to rewind
+// the stream before importing.
+func ReadExportHeader(r io.Reader) (IonExportHeader, IonErr) {
+	er := exportReader{r: r, crc: crc32.NewIEEE()}
+	return er.readHeader()
+}
+
+// dictImport inserts every record of an export stream into dict. The key
+// type, sizes and unique-key mode must match dict, the dictionary type may
+// differ. Records
+// are inserted as they are read, so if the trailing checksum does not match
+// the dictionary already holds the records read before and should be
+// discarded by the caller. An empty dict whose Bloom filter is sized like the
+// one saved in the stream takes a copy of it once the stream checks out.
+func dictImport(dict *IonDictionary, r io.Reader) IonErr {
+	parent := dict.instance
+	kSize := parent.record.keySize
+	vSize := parent.record.valueSize
+	er := exportReader{r: r, crc: crc32.NewIEEE()}
+
+	header, err := er.readHeader()
+	if err != ErrOk {
+		return err
+	}
+	if header.KeyType != parent.kType || header.KeySize != kSize || header.ValSize != vSize {
+		return ErrUnableToConvert
+	}
+	if header.UniqueKeys != parent.uniqueKeys {
+		return ErrUnableToConvert
+	}
+
+	var count uint64
+	var restored *ionBloomFilter
+	bloomRead := false
+	val := make([]byte, vSize+1)
+	for {
+		tag, err := er.readU8()
+		if err != ErrOk {
+			return err
+		}
+		if tag == exportTagEnd {
+			break
+		}
+		if tag == exportTagBloom && count == 0 && !bloomRead {
+			if restored, err = er.readBloom(parent.bloom); err != ErrOk {
+				return err
+			}
+			bloomRead = true
+			continue
+		}
+		if tag != exportTagRecord {
+			return ErrCorruptedData
+		}
+
+		keyLen, err := er.readU32()
+		if err != ErrOk {
+			return err
+		}
+		if parent.kType != KeyTypeNullTerminatedString && IonKeySize(keyLen) != kSize {
+			return ErrCorruptedData
+		}
+		if keyLen > exportMaxKeyLen {
+			return ErrCorruptedData
+		}
+		key := make([]byte, int(keyLen)+1)
+		if err = er.read(key[:keyLen]); err != ErrOk {
+			return err
+		}
+
+		valLen, err := er.readU32()
+		if err != ErrOk {
+			return err
+		}
+		if IonValueSize(valLen) != vSize {
+			return ErrCorruptedData
+		}
+		if err = er.read(val[:vSize]); err != ErrOk {
+			return err
+		}
+
+		status := dictInsert(dict, importKey(parent.kType, key[:keyLen]), IonValue(unsafe.Pointer(&val[0])))
+		if status.Err != ErrOk {
+			return status.Err
+		}
+		count++
+	}
+
+	total, err := er.readU64()
+	if err != ErrOk {
+		return err
+	}
+	sum := er.crc.Sum32()
+	if _, rerr := io.ReadFull(r, er.buf[:4]); rerr != nil {
+		return ErrFileHitEof
+	}
+	if total != count || binary.LittleEndian.Uint32(er.buf[:4]) != sum {
+		return ErrCorruptedData
+	}
+	if restored != nil {
+		parent.bloom = restored
+	}
+	return ErrOk
+}
+
+// readBloom reads a bloom section and returns the filter in it if it can
+// stand in for bf: bf is empty and the same size. Otherwise the section is
+// skipped and the filter is nil.
+func (er *exportReader) readBloom(bf *ionBloomFilter) (*ionBloomFilter, IonErr) {
+	size, err := er.readU32()
+	if err != ErrOk {
+		return nil, err
+	}
+	if bf == nil || bf.items > 0 || int64(size) != bloomFilterSize(bf.m) {
+		return nil, er.skip(int64(size))
+	}
+	filter := make([]byte, size)
+	if err := er.read(filter); err != ErrOk {
+		return nil, err
+	}
+	restored, err := readBloomFilter(bytes.NewReader(filter))
+	if err != ErrOk || restored.m != bf.m || restored.k != bf.k {
+		return nil, ErrCorruptedData
+	}
+	return restored, ErrOk
+}
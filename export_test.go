@@ -0,0 +1,228 @@
+package iondb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+	"unsafe"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	one := 1
+	src := NewSkipList[int, int](1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(one)), 7)
+	for i := 0; i < 20; i++ {
+		src.Insert(i, i*10)
+	}
+	src.Insert(5, 55)
+
+	var buf bytes.Buffer
+	t.Run("export", func(t *testing.T) {
+		if err := src.Export(&buf); err != ErrOk {
+			t.Errorf("got err = %v, want = %v", err, ErrOk)
+		}
+		header, err := ReadExportHeader(bytes.NewReader(buf.Bytes()))
+		if err != ErrOk {
+			t.Fatalf("got err = %v, want = %v", err, ErrOk)
+		}
+		if header.DictType != DictionaryTypeSkipList {
+			t.Errorf("got dictType = %v, want = %v", header.DictType, DictionaryTypeSkipList)
+		}
+		if header.KeyType != KeyTypeNumericSigned {
+			t.Errorf("got keyType = %v, want = %v", header.KeyType, KeyTypeNumericSigned)
+		}
+		if header.KeySize != IonKeySize(unsafe.Sizeof(one)) {
+			t.Errorf("got keySize = %v, want = %v", header.KeySize, unsafe.Sizeof(one))
+		}
+	})
+
+	t.Run("import", func(t *testing.T) {
+		dst := NewSkipList[int, int](2, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(one)), 7)
+		if err := dst.Import(bytes.NewReader(buf.Bytes())); err != ErrOk {
+			t.Fatalf("got err = %v, want = %v", err, ErrOk)
+		}
+		count := 0
+		cursor := dst.AllRecords()
+		for cursor.Next(); cursor.HasNext(); cursor.Next() {
+			count++
+		}
+		if count != 21 {
+			t.Errorf("got count = %v, want = %v", count, 21)
+		}
+		if got := dst.Get(7); got != 70 {
+			t.Errorf("got val = %v, want = %v", got, 70)
+		}
+		dups := 0
+		cursor = dst.Equality(5)
+		for cursor.Next(); cursor.HasNext(); cursor.Next() {
+			dups++
+		}
+		if dups != 2 {
+			t.Errorf("got duplicates = %v, want = %v", dups, 2)
+		}
+	})
+}
+
+func TestExportImportStringKeys(t *testing.T) {
+	str := "key"
+	src := NewSkipList[string, int](1, KeyTypeNullTerminatedString, int(unsafe.Sizeof(str)), 8, 7)
+	src.Insert("banana", 2)
+	src.Insert("apple", 1)
+
+	var buf bytes.Buffer
+	src.Export(&buf)
+
+	t.Run("string keys", func(t *testing.T) {
+		dst := NewSkipList[string, int](2, KeyTypeNullTerminatedString, int(unsafe.Sizeof(str)), 8, 7)
+		if err := dst.Import(&buf); err != ErrOk {
+			t.Fatalf("got err = %v, want = %v", err, ErrOk)
+		}
+		if got := dst.Get("banana"); got != 2 {
+			t.Errorf("got val = %v, want = %v", got, 2)
+		}
+		if got := dst.Get("apple"); got != 1 {
+			t.Errorf("got val = %v, want = %v", got, 1)
+		}
+	})
+}
+
+func TestImportOtherDictionaryType(t *testing.T) {
+	one := 1
+	src := NewSkipList[int, int](1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(one)), 7)
+	src.Insert(1, 2)
+	var buf bytes.Buffer
+	src.Export(&buf)
+
+	// a stream taken from another backend, trailer checksum redone.
+	stream := buf.Bytes()
+	stream[5] = DictionaryTypeBppTree
+	trailer := len(stream) - 4
+	binary.LittleEndian.PutUint32(stream[trailer:], crc32.ChecksumIEEE(stream[:trailer]))
+
+	dst := NewSkipList[int, int](2, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(one)), 7)
+	if err := dst.Import(bytes.NewReader(stream)); err != ErrOk {
+		t.Fatalf("got err = %v, want = %v", err, ErrOk)
+	}
+	if got := dst.Get(1); got != 2 {
+		t.Errorf("got val = %v, want = %v", got, 2)
+	}
+}
+
+func TestExportPointerValues(t *testing.T) {
+	one := 1
+	str := ""
+	dict := NewSkipList[int, string](1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(str)), 7)
+	dict.Insert(1, "value")
+	var buf bytes.Buffer
+	if err := dict.Export(&buf); err != ErrUnableToConvert || buf.Len() != 0 {
+		t.Errorf("got err = %v with %v bytes written, want = %v", err, buf.Len(), ErrUnableToConvert)
+	}
+	if err := dict.Import(bytes.NewReader(nil)); err != ErrUnableToConvert {
+		t.Errorf("got import err = %v, want = %v", err, ErrUnableToConvert)
+	}
+
+	var ptr *int
+	ptrKeys := NewSkipList[*int, int](1, KeyTypeCharArray, int(unsafe.Sizeof(ptr)), uint(unsafe.Sizeof(one)), 7)
+	if err := ptrKeys.Export(&buf); err != ErrUnableToConvert {
+		t.Errorf("got err = %v for pointer keys, want = %v", err, ErrUnableToConvert)
+	}
+}
+
+func TestImportRejectsBadStream(t *testing.T) {
+	one := 1
+	str := "key"
+	src := NewSkipList[int, int](1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(one)), 7)
+	src.Insert(1, 2)
+	src.Insert(3, 4)
+	var buf bytes.Buffer
+	src.Export(&buf)
+
+	corrupt := append([]byte(nil), buf.Bytes()...)
+	corrupt[exportHeaderSize+6] ^= 0xff
+	truncated := buf.Bytes()[:buf.Len()-3]
+	unique := append([]byte(nil), buf.Bytes()...)
+	unique[7] |= exportFlagUniqueKeys
+
+	strSrc := NewSkipList[string, int](1, KeyTypeNullTerminatedString, int(unsafe.Sizeof(str)), 8, 7)
+	strSrc.Insert("apple", 1)
+	var strBuf bytes.Buffer
+	strSrc.Export(&strBuf)
+	// the key length follows the header and the record tag.
+	withKeyLen := func(keyLen uint32) []byte {
+		stream := append([]byte(nil), strBuf.Bytes()...)
+		binary.LittleEndian.PutUint32(stream[exportHeaderSize+1:], keyLen)
+		return stream
+	}
+
+	tests := []struct {
+		name    string
+		kSize   int
+		strings bool
+		input   []byte
+		want    IonErr
+	}{
+		{
+			name:  "checksum mismatch",
+			kSize: int(unsafe.Sizeof(one)),
+			input: corrupt,
+			want:  ErrCorruptedData,
+		},
+		{
+			name:  "truncated",
+			kSize: int(unsafe.Sizeof(one)),
+			input: truncated,
+			want:  ErrFileHitEof,
+		},
+		{
+			name:  "key size mismatch",
+			kSize: 4,
+			input: buf.Bytes(),
+			want:  ErrUnableToConvert,
+		},
+		{
+			name:  "unique keys mismatch",
+			kSize: int(unsafe.Sizeof(one)),
+			input: unique,
+			want:  ErrUnableToConvert,
+		},
+		{
+			name:    "wrapping key length",
+			kSize:   int(unsafe.Sizeof(str)),
+			strings: true,
+			input:   withKeyLen(0xFFFFFFFF),
+			want:    ErrCorruptedData,
+		},
+		{
+			name:    "oversized key length",
+			kSize:   int(unsafe.Sizeof(str)),
+			strings: true,
+			input:   withKeyLen(exportMaxKeyLen + 1),
+			want:    ErrCorruptedData,
+		},
+		{
+			name:    "key length past the stream",
+			kSize:   int(unsafe.Sizeof(str)),
+			strings: true,
+			input:   withKeyLen(1000),
+			want:    ErrFileHitEof,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var err IonErr
+			if tt.strings {
+				dst := NewSkipList[string, int](2, KeyTypeNullTerminatedString, tt.kSize, 8, 7)
+				err = dst.Import(bytes.NewReader(tt.input))
+			} else {
+				dst := NewSkipList[int, int](2, KeyTypeNumericSigned, tt.kSize, uint(unsafe.Sizeof(one)), 7)
+				err = dst.Import(bytes.NewReader(tt.input))
+			}
+			if err != tt.want {
+				t.Errorf("got err = %v, want = %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package iondb
+
+import (
+	"bytes"
+	"testing"
+	"unsafe"
+)
+
+// fuzzKeySize picks a numeric key size out of the fuzzed byte.
+func fuzzKeySize(size uint8) IonKeySize {
+	return IonKeySize(1) << (size % 4)
+}
+
+// fuzzSign is -1, 0 or 1 like the comparators return.
+func fuzzSign(cmp int8) int8 {
+	if cmp < 0 {
+		return -1
+	} else if cmp > 0 {
+		return 1
+	}
+	return 0
+}
+
+// fuzzCheckOrder checks that compare is antisymmetric on every pair of keys
+// and transitive on the three.
+func fuzzCheckOrder(t *testing.T, compare IonDictionaryCompare, keys [3]IonKey, kSize IonKeySize) {
+	for i := range keys {
+		for j := range keys {
+			if ab, ba := fuzzSign(compare(keys[i], keys[j], kSize)), fuzzSign(compare(keys[j], keys[i], kSize)); ab != -ba {
+				t.Fatalf("got compare(%v, %v) = %v and reversed = %v, want opposite signs", i, j, ab, ba)
+			}
+		}
+	}
+	for i := range keys {
+		for j := range keys {
+			for k := range keys {
+				if compare(keys[i], keys[j], kSize) <= 0 && compare(keys[j], keys[k], kSize) <= 0 && compare(keys[i], keys[k], kSize) > 0 {
+					t.Fatalf("got %v <= %v <= %v but %v > %v", i, j, k, i, k)
+				}
+			}
+		}
+	}
+}
+
+// fuzzNumericKey stores the low kSize bytes of v in host byte order.
+func fuzzNumericKey(v uint64, kSize IonKeySize) IonKey {
+	buf := make([]byte, kSize)
+	for i := IonKeySize(0); i < kSize; i++ {
+		shift := 8 * i
+		if hostIsBigEndian() {
+			shift = 8 * (kSize - 1 - i)
+		}
+		buf[i] = byte(v >> shift)
+	}
+	return IonKey(unsafe.Pointer(&buf[0]))
+}
+
+func FuzzCompareSignedValue(f *testing.F) {
+	f.Add(int64(1), int64(-1), int64(256), uint8(3))
+	f.Add(int64(-128), int64(127), int64(0), uint8(0))
+	f.Add(int64(-256), int64(-255), int64(255), uint8(1))
+	f.Fuzz(func(t *testing.T, a, b, c int64, size uint8) {
+		kSize := fuzzKeySize(size)
+		shift := 64 - 8*kSize
+		vals := [3]int64{a << shift >> shift, b << shift >> shift, c << shift >> shift}
+		var keys [3]IonKey
+		for i, v := range vals {
+			keys[i] = fuzzNumericKey(uint64(v), kSize)
+		}
+		fuzzCheckOrder(t, dictCompareSignedValue, keys, kSize)
+		for i := range vals {
+			for j := range vals {
+				want := int8(0)
+				if vals[i] < vals[j] {
+					want = -1
+				} else if vals[i] > vals[j] {
+					want = 1
+				}
+				if got := fuzzSign(dictCompareSignedValue(keys[i], keys[j], kSize)); got != want {
+					t.Fatalf("got compare(%v, %v) = %v, want = %v", vals[i], vals[j], got, want)
+				}
+			}
+		}
+	})
+}
+
+func FuzzCompareUnsignedValue(f *testing.F) {
+	f.Add(uint64(1), uint64(2), uint64(256), uint8(3))
+	f.Add(uint64(255), uint64(128), uint64(0), uint8(0))
+	f.Fuzz(func(t *testing.T, a, b, c uint64, size uint8) {
+		kSize := fuzzKeySize(size)
+		shift := 64 - 8*kSize
+		vals := [3]uint64{a << shift >> shift, b << shift >> shift, c << shift >> shift}
+		var keys [3]IonKey
+		for i, v := range vals {
+			keys[i] = fuzzNumericKey(v, kSize)
+		}
+		fuzzCheckOrder(t, dictCompareUnsignedValue, keys, kSize)
+		for i := range vals {
+			for j := range vals {
+				want := int8(0)
+				if vals[i] < vals[j] {
+					want = -1
+				} else if vals[i] > vals[j] {
+					want = 1
+				}
+				if got := fuzzSign(dictCompareUnsignedValue(keys[i], keys[j], kSize)); got != want {
+					t.Fatalf("got compare(%v, %v) = %v, want = %v", vals[i], vals[j], got, want)
+				}
+			}
+		}
+	})
+}
+
+func FuzzCompareCharArray(f *testing.F) {
+	f.Add([]byte("abc"), []byte("abd"), []byte("ab\x80"))
+	f.Add([]byte{0, 0xff}, []byte{0xff, 0}, []byte{0x7f, 0x80})
+	f.Fuzz(func(t *testing.T, a, b, c []byte) {
+		kSize := len(a)
+		if len(b) < kSize {
+			kSize = len(b)
+		}
+		if len(c) < kSize {
+			kSize = len(c)
+		}
+		if kSize == 0 {
+			return
+		}
+		keys := [3]IonKey{IonKey(unsafe.Pointer(&a[0])), IonKey(unsafe.Pointer(&b[0])), IonKey(unsafe.Pointer(&c[0]))}
+		fuzzCheckOrder(t, dictCompareCharArray, keys, kSize)
+		if equal := bytes.Equal(a[:kSize], b[:kSize]); equal != (dictCompareCharArray(keys[0], keys[1], kSize) == 0) {
+			t.Fatalf("got equal = %v, compare = %v", equal, dictCompareCharArray(keys[0], keys[1], kSize))
+		}
+	})
+}
+
+// FuzzSkipListOperations reads the input as operations of three bytes, an
+// opcode, a key and a value, runs them on a skip list and on the model of
+// the conformance harness, and checks they agree, that the skip list stays
+// valid and that the cursor output is sorted.
+func FuzzSkipListOperations(f *testing.F) {
+	f.Add([]byte{0, 5, 1, 0, 5, 2, 0, 250, 3, 3, 5, 0, 2, 250, 9})
+	f.Add([]byte{0, 128, 1, 0, 127, 2, 1, 128, 0, 4, 100, 0, 0, 1, 1, 5, 1, 2})
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		dict := NewSkipList[int64, int64](-1, KeyTypeNumericSigned, 8, 8, 7, WithSeed(1))
+		model := &conformanceModel{compare: dict.dict.instance.compare}
+		for i := 0; i+2 < len(ops); i += 3 {
+			key := int64(int8(ops[i+1]))
+			val := int64(ops[i+2])
+			switch ops[i] % 6 {
+			case 0:
+				dict.Insert(key, val)
+				model.insert(key, val)
+			case 1:
+				if got, want := dict.DeleteRecord(key), model.remove(key); got != want {
+					t.Fatalf("got delete(%v) = %v, want = %v", key, got, want)
+				}
+			case 2:
+				if got, want := dict.Update(key, val), model.update(key, val); got != want {
+					t.Fatalf("got update(%v) = %v, want = %v", key, got, want)
+				}
+			case 3:
+				got := dict.Get(key)
+				want := model.equality(key)
+				if (dict.LastStatus.Err == ErrOk) != (len(want) > 0) || (len(want) > 0 && got != want[0].val) {
+					t.Fatalf("got get(%v) = %v, %v, want = %v", key, got, dict.LastStatus.Err, want)
+				}
+			case 4:
+				hi := key + int64(val%32)
+				if got, want := dict.CountRange(key, hi), len(model.between(key, hi)); got != want {
+					t.Fatalf("got count(%v, %v) = %v, want = %v", key, hi, got, want)
+				}
+			case 5:
+				if got, want := dict.Rank(key), len(model.between(-129, key-1)); got != want {
+					t.Fatalf("got rank(%v) = %v, want = %v", key, got, want)
+				}
+			}
+			if err := dict.Validate(); err != nil {
+				t.Fatalf("got invalid skip list after operation %v: %v", i/3, err)
+			}
+		}
+
+		var got []conformanceRecord
+		cursor := dict.AllRecords()
+		for cursor.Next(); cursor.HasNext(); cursor.Next() {
+			got = append(got, conformanceRecord{cursor.GetKey(), cursor.GetValue()})
+		}
+		for i := 1; i < len(got); i++ {
+			if got[i-1].key > got[i].key {
+				t.Fatalf("got keys out of order: %v before %v", got[i-1].key, got[i].key)
+			}
+		}
+		if len(got) != len(model.records) {
+			t.Fatalf("got records = %v, want = %v", got, model.records)
+		}
+		for i := range got {
+			if got[i] != model.records[i] {
+				t.Fatalf("got records = %v, want = %v", got, model.records)
+			}
+		}
+	})
+}
@@ -49,6 +49,8 @@ const (
 	ErrUninitialized
 	ErrOutOfBounds
 	ErrSortedOrderViolation
+	ErrCorruptedData
+	ErrInterrupted
 )
 
 type IonKey unsafe.Pointer
@@ -0,0 +1,123 @@
+package iondb
+
+import "unsafe"
+
+// slSecondaryIndex is notified by a SkipList of every record it gains or loses.
+type slSecondaryIndex[K, V any] interface {
+	indexInsert(key K, val V)
+	indexDelete(key K, val V)
+	rebuild()
+}
+
+// SecondaryIndex keeps a dictionary from a field of the primary's values to
+// the primary key, so records can be looked up by that field. The primary
+// updates it on every change, there is no need to keep it in sync by hand.
+type SecondaryIndex[K, V, IK any] struct {
+	primary  *SkipList[K, V]
+	index    *SkipList[IK, K]
+	extract  func(V) IK
+	kType    IonKeyType
+	kSize    IonKeySize
+	dictSize IonDictionarySize
+}
+
+// NewSecondaryIndex indexes the records of primary by extract(value). kType
+// and kSize describe IK. Records already in primary are indexed right away.
+func NewSecondaryIndex[K, V, IK any](primary *SkipList[K, V], kType IonKeyType, kSize IonKeySize, dictSize IonDictionarySize, extract func(V) IK) *SecondaryIndex[K, V, IK] {
+	si := new(SecondaryIndex[K, V, IK])
+	si.primary = primary
+	si.extract = extract
+	si.kType = kType
+	si.kSize = kSize
+	si.dictSize = dictSize
+	si.rebuild()
+
+	primary.indexes = append(primary.indexes, si)
+	primary.dict.instance.expired = primary.unindexExpired
+	return si
+}
+
+func (si *SecondaryIndex[K, V, IK]) indexInsert(key K, val V) {
+	si.index.Insert(si.extract(val), key)
+}
+
+// indexDelete removes the entry of key for val. DeleteValue compares primary
+// keys as K, so a string key built at runtime finds the entry of its literal.
+func (si *SecondaryIndex[K, V, IK]) indexDelete(key K, val V) {
+	si.index.DeleteValue(si.extract(val), key)
+}
+
+// rebuild indexes every record of the primary into a fresh index dictionary.
+func (si *SecondaryIndex[K, V, IK]) rebuild() {
+	si.index = NewSkipList[IK, K](-1, si.kType, si.kSize, IonValueSize(si.primary.dict.instance.record.keySize), si.dictSize)
+	cursor := si.primary.AllRecords()
+	for cursor.Next(); cursor.HasNext(); cursor.Next() {
+		si.indexInsert(cursor.GetKey(), cursor.GetValue())
+	}
+}
+
+// Lookup returns a cursor over the primary records whose indexed field is ik.
+func (si *SecondaryIndex[K, V, IK]) Lookup(ik IK) *Cursor[K, V] {
+	return si.primaryCursor(si.index.Equality(ik))
+}
+
+// LookupRange returns a cursor over the primary records whose indexed field
+// lies between minKey and maxKey, in index order.
+func (si *SecondaryIndex[K, V, IK]) LookupRange(minKey, maxKey IK) *Cursor[K, V] {
+	return si.primaryCursor(si.index.Range(minKey, maxKey))
+}
+
+// primaryCursor turns a cursor over index entries into a cursor over the
+// primary records they point to. A primary key can have several entries
+// when its duplicates share a field value, so every primary key is visited
+// once and only the duplicates whose field is still in range are returned.
+func (si *SecondaryIndex[K, V, IK]) primaryCursor(indexCursor *Cursor[IK, K]) *Cursor[K, V] {
+	primaryDict := &(si.primary.dict)
+	kSize := primaryDict.instance.record.keySize
+	vSize := primaryDict.instance.record.valueSize
+	visited := NewSkipList[K, struct{}](-1, si.primary.keyType, kSize, 0, si.dictSize, WithUniqueKeys())
+	var primaryCursor *Cursor[K, V]
+
+	var cur Cursor[K, V]
+	cur.dict = primaryDict
+	cur.record.key = IonKey(alloc(uintptr(kSize), nil))
+	cur.record.value = IonValue(alloc(uintptr(vSize), nil))
+	cur.dictCursor = new(IonDictCursor)
+	cur.dictCursor.dict = primaryDict
+	cur.dictCursor.status = csCursorInitialized
+	cur.dictCursor.destroy = func(cursorPtr **IonDictCursor) {
+		indexCursor.dictCursor.destroy(&(indexCursor.dictCursor))
+		*cursorPtr = nil
+	}
+	cur.dictCursor.next = func(cursor *IonDictCursor, record *IonRecord) IonCursorStatus {
+		if cursor.status != csCursorInitialized && cursor.status != csCursorActive {
+			return cursor.status
+		}
+		for {
+			if primaryCursor != nil {
+				for primaryCursor.Next() {
+					val := primaryCursor.GetValue()
+					ik := si.extract(val)
+					if testPredicate(indexCursor.dictCursor, IonKey(unsafe.Pointer(&ik))) {
+						key := primaryCursor.GetKey()
+						memcpy(unsafe.Pointer(record.key), unsafe.Pointer(&key), uintptr(kSize))
+						memcpy(unsafe.Pointer(record.value), unsafe.Pointer(&val), uintptr(vSize))
+						cursor.status = csCursorActive
+						return cursor.status
+					}
+				}
+			}
+			if !indexCursor.Next() {
+				cursor.status = csEndOfResults
+				return cursor.status
+			}
+			key := indexCursor.GetValue()
+			if visited.Insert(key, struct{}{}).Err != ErrOk {
+				primaryCursor = nil
+				continue
+			}
+			primaryCursor = si.primary.Equality(key)
+		}
+	}
+	return &cur
+}
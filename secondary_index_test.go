@@ -0,0 +1,169 @@
+package iondb
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+type testReading struct {
+	SensorID int
+	Value    int
+}
+
+func collectKeys[K, V any](cursor *Cursor[K, V]) []K {
+	var keys []K
+	for cursor.Next(); cursor.HasNext(); cursor.Next() {
+		keys = append(keys, cursor.GetKey())
+	}
+	return keys
+}
+
+func TestSecondaryIndex(t *testing.T) {
+	one := 1
+	var reading testReading
+	primary := NewSkipList[int, testReading](-1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(reading)), 7)
+	primary.Insert(1, testReading{10, 100})
+	primary.Insert(2, testReading{20, 200})
+	index := NewSecondaryIndex(primary, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), 7, func(r testReading) int {
+		return r.SensorID
+	})
+	primary.Insert(3, testReading{10, 300})
+	primary.Insert(4, testReading{30, 400})
+
+	sameKeys := func(got []int, want []int) bool {
+		if len(got) != len(want) {
+			return false
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	t.Run("lookup", func(t *testing.T) {
+		if got := collectKeys(index.Lookup(10)); !sameKeys(got, []int{1, 3}) {
+			t.Errorf("got keys = %v, want = %v", got, []int{1, 3})
+		}
+		if got := collectKeys(index.Lookup(99)); len(got) != 0 {
+			t.Errorf("got keys = %v, want = %v", got, []int{})
+		}
+		cursor := index.Lookup(30)
+		cursor.Next()
+		if cursor.GetValue().Value != 400 {
+			t.Errorf("got val = %v, want = %v", cursor.GetValue().Value, 400)
+		}
+	})
+
+	t.Run("lookup range", func(t *testing.T) {
+		if got := collectKeys(index.LookupRange(15, 30)); !sameKeys(got, []int{2, 4}) {
+			t.Errorf("got keys = %v, want = %v", got, []int{2, 4})
+		}
+	})
+
+	t.Run("update moves entry", func(t *testing.T) {
+		primary.Update(1, testReading{20, 101})
+		if got := collectKeys(index.Lookup(10)); !sameKeys(got, []int{3}) {
+			t.Errorf("got keys = %v, want = %v", got, []int{3})
+		}
+		if got := collectKeys(index.Lookup(20)); !sameKeys(got, []int{2, 1}) {
+			t.Errorf("got keys = %v, want = %v", got, []int{2, 1})
+		}
+	})
+
+	t.Run("delete removes entry", func(t *testing.T) {
+		primary.DeleteRecord(2)
+		if got := collectKeys(index.Lookup(20)); !sameKeys(got, []int{1}) {
+			t.Errorf("got keys = %v, want = %v", got, []int{1})
+		}
+	})
+
+	t.Run("duplicates", func(t *testing.T) {
+		primary.Insert(3, testReading{40, 301})
+		primary.Insert(3, testReading{10, 302})
+		cursor := index.Lookup(10)
+		var vals []int
+		for cursor.Next(); cursor.HasNext(); cursor.Next() {
+			vals = append(vals, cursor.GetValue().Value)
+		}
+		if !sameKeys(vals, []int{300, 302}) {
+			t.Errorf("got vals = %v, want = %v", vals, []int{300, 302})
+		}
+		primary.DeleteValue(3, testReading{10, 300})
+		if got := collectKeys(index.Lookup(40)); !sameKeys(got, []int{3}) {
+			t.Errorf("got keys = %v, want = %v", got, []int{3})
+		}
+		if got := collectKeys(index.Lookup(10)); !sameKeys(got, []int{3}) {
+			t.Errorf("got keys = %v, want = %v", got, []int{3})
+		}
+	})
+}
+
+func TestSecondaryIndexExpiry(t *testing.T) {
+	one := 1
+	var reading testReading
+	now := time.Unix(1000, 0)
+	primary := NewSkipList[int, testReading](-1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(reading)), 7, WithUniqueKeys(), WithClock(func() time.Time { return now }))
+	index := NewSecondaryIndex(primary, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), 7, func(r testReading) int {
+		return r.SensorID
+	})
+	entries := func() int {
+		return index.index.MemoryUsage().Records
+	}
+	for key := 0; key < 30; key++ {
+		primary.InsertWithTTL(key, testReading{key % 3, key}, time.Duration(1+key%2)*time.Minute)
+	}
+	now = now.Add(90 * time.Second)
+
+	tests := []struct {
+		name string
+		run  func()
+		want int
+	}{
+		// the odd keys live two minutes.
+		{name: "cursor unlinks", run: func() { collectKeys(primary.Range(0, 8)) }, want: 25},
+		{name: "unique insert replaces", run: func() { primary.Insert(10, testReading{7, 10}) }, want: 25},
+		{name: "update replaces", run: func() { primary.Update(12, testReading{7, 12}) }, want: 25},
+		{name: "sweep", run: func() { primary.Sweep() }, want: 17},
+	}
+	for _, tt := range tests {
+		tt.run()
+		if got := entries(); got != tt.want {
+			t.Errorf("got %v index entries after %v, want = %v", got, tt.name, tt.want)
+		}
+	}
+	if got := collectKeys(index.Lookup(7)); len(got) != 2 || got[0] != 10 || got[1] != 12 {
+		t.Errorf("got keys = %v, want = %v", got, []int{10, 12})
+	}
+}
+
+func TestSecondaryIndexStringKeys(t *testing.T) {
+	one := 1
+	str := ""
+	var reading testReading
+	primary := NewSkipList[string, testReading](-1, KeyTypeNullTerminatedString, int(unsafe.Sizeof(str)), uint(unsafe.Sizeof(reading)), 7)
+	index := NewSecondaryIndex(primary, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), 7, func(r testReading) int {
+		return r.SensorID
+	})
+	primary.Insert("dev-1", testReading{10, 100})
+	primary.Insert("dev-2", testReading{20, 200})
+	entries := func() int {
+		return index.index.MemoryUsage().Records
+	}
+
+	// keys built at runtime have other backing arrays than the literals.
+	primary.Update(strings.Join([]string{"dev", "2"}, "-"), testReading{30, 300})
+	if got := collectKeys(index.Lookup(20)); len(got) != 0 {
+		t.Errorf("got keys = %v for the old field, want = %v", got, []string{})
+	}
+	if got := collectKeys(index.Lookup(30)); len(got) != 1 || got[0] != "dev-2" {
+		t.Errorf("got keys = %v, want = %v", got, []string{"dev-2"})
+	}
+	primary.DeleteRecord(strings.Join([]string{"dev", "1"}, "-"))
+	if got := entries(); got != 1 {
+		t.Errorf("got %v index entries, want = %v", got, 1)
+	}
+}
@@ -1,7 +1,8 @@
 package iondb
 
 import (
-	"math/rand"
+	"io"
+	"time"
 	"unsafe"
 )
 
@@ -15,9 +16,10 @@ type SkipList[K, V any] struct {
 	valSize    IonValueSize
 	dictSize   IonDictionarySize
 	LastStatus IonStatus
+	indexes    []slSecondaryIndex[K, V]
 }
 
-func NewSkipList[K, V any](id IonDictionaryID, kType IonKeyType, kSize IonKeySize, vSize IonValueSize, dictSize IonDictionarySize) *SkipList[K, V] {
+func NewSkipList[K, V any](id IonDictionaryID, kType IonKeyType, kSize IonKeySize, vSize IonValueSize, dictSize IonDictionarySize, opts ...IonDictionaryOption) *SkipList[K, V] {
 	sl := new(SkipList[K, V])
 	SldictInit(&(sl.handler))
 
@@ -26,16 +28,94 @@ func NewSkipList[K, V any](id IonDictionaryID, kType IonKeyType, kSize IonKeySiz
 	sl.valSize = vSize
 	sl.dictSize = dictSize
 
-	err := dictCreate(&(sl.handler), &(sl.dict), id, kType, kSize, vSize, dictSize)
+	var conf IonDictionaryConfigInfo
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	if conf.maxHeight > 0 {
+		sl.dictSize = conf.maxHeight
+	}
+
+	err := dictCreate(&(sl.handler), &(sl.dict), id, kType, kSize, vSize, sl.dictSize)
+	if err == ErrOk && conf.codec != nil && valueHasPointers[V]() {
+		err = ErrUnableToConvert
+	}
+	if err == ErrOk {
+		dictApplyConfig(&(sl.dict), &conf)
+		err = slApplyConfig((*ionSkipList)(unsafe.Pointer(sl.dict.instance)), &conf)
+	}
 
 	sl.LastStatus.Err = err
 	return sl
 }
 
+// WithSeed seeds the random source a skip list draws node heights from, so
+// the same inserts build the same structure.
+func WithSeed(seed int64) IonDictionaryOption {
+	return func(conf *IonDictionaryConfigInfo) {
+		conf.seed = seed
+		conf.seeded = true
+	}
+}
+
+// WithLevelProbability sets the chance pnum/pden of a skip list node
+// reaching one level higher. It must be between 0 and 1, the default is 1/4.
+func WithLevelProbability(pnum, pden int) IonDictionaryOption {
+	return func(conf *IonDictionaryConfigInfo) {
+		conf.pnum = pnum
+		conf.pden = pden
+	}
+}
+
+// WithSlabAllocation makes a skip list carve its nodes, keys, values and
+// links from slabs of nodesPerSlab nodes and reuse deleted nodes, instead of
+// allocating each of them on every insert. A cursor must not be kept across
+// a delete followed by an insert, as the insert may reuse the deleted node.
+func WithSlabAllocation(nodesPerSlab int) IonDictionaryOption {
+	return func(conf *IonDictionaryConfigInfo) {
+		conf.slabNodes = nodesPerSlab
+	}
+}
+
+// WithMaxHeight sets how many levels a skip list has in place of dictSize.
+func WithMaxHeight(height IonDictionarySize) IonDictionaryOption {
+	return func(conf *IonDictionaryConfigInfo) {
+		conf.maxHeight = height
+	}
+}
+
 func (sl *SkipList[K, V]) Insert(key K, val V) IonStatus {
 	ionKey := (IonKey)(unsafe.Pointer(&key))
 	ionVal := (IonValue)(unsafe.Pointer(&val))
 	status := dictInsert(&(sl.dict), ionKey, ionVal)
+	if status.Err == ErrOk {
+		for _, index := range sl.indexes {
+			index.indexInsert(key, val)
+		}
+	}
+	sl.LastStatus = status
+	return status
+}
+
+// InsertWithTTL inserts a record that expires ttl from now, whatever the
+// dictionary's own TTL is. A ttl of 0 never expires.
+func (sl *SkipList[K, V]) InsertWithTTL(key K, val V, ttl time.Duration) IonStatus {
+	ionKey := (IonKey)(unsafe.Pointer(&key))
+	ionVal := (IonValue)(unsafe.Pointer(&val))
+	status := dictInsertTTL(&(sl.dict), ionKey, ionVal, ttl)
+	if status.Err == ErrOk {
+		for _, index := range sl.indexes {
+			index.indexInsert(key, val)
+		}
+	}
+	sl.LastStatus = status
+	return status
+}
+
+// Sweep removes every expired record and returns how many were removed.
+func (sl *SkipList[K, V]) Sweep() IonStatus {
+	status := slSweep((*ionSkipList)(unsafe.Pointer(sl.dict.instance)))
+	dictBloomCheck(&(sl.dict))
 	sl.LastStatus = status
 	return status
 }
@@ -50,16 +130,143 @@ func (sl *SkipList[K, V]) Get(key K) V {
 }
 
 func (sl *SkipList[K, V]) DeleteRecord(key K) IonStatus {
+	var oldVals []V
+	if len(sl.indexes) > 0 {
+		oldVals = sl.values(key)
+	}
 	ionKey := (IonKey)(unsafe.Pointer(&key))
 	status := dictDelete(&(sl.dict), ionKey)
+	if status.Err == ErrOk {
+		sl.unindex(key, oldVals)
+	}
 	sl.LastStatus = status
 	return status
 }
 
 func (sl *SkipList[K, V]) Update(key K, val V) IonStatus {
+	var oldVals []V
+	if len(sl.indexes) > 0 {
+		oldVals = sl.values(key)
+	}
 	ionKey := (IonKey)(unsafe.Pointer(&key))
 	ionVal := (IonValue)(unsafe.Pointer(&val))
 	status := dictUpdate(&(sl.dict), ionKey, ionVal)
+	if status.Err == ErrOk && len(sl.indexes) > 0 {
+		sl.unindex(key, oldVals)
+		for i := IonResultCount(0); i < status.ResCnt; i++ {
+			for _, index := range sl.indexes {
+				index.indexInsert(key, val)
+			}
+		}
+	}
+	sl.LastStatus = status
+	return status
+}
+
+// values returns the values of every duplicate of key in insertion order.
+func (sl *SkipList[K, V]) values(key K) []V {
+	var vals []V
+	cursor := sl.Equality(key)
+	for cursor.Next(); cursor.HasNext(); cursor.Next() {
+		vals = append(vals, cursor.GetValue())
+	}
+	return vals
+}
+
+// unindex removes the records key had with oldVals from every secondary index.
+func (sl *SkipList[K, V]) unindex(key K, oldVals []V) {
+	for _, oldVal := range oldVals {
+		for _, index := range sl.indexes {
+			index.indexDelete(key, oldVal)
+		}
+	}
+}
+
+// unindexExpired removes an expired record the handler dropped from every
+// secondary index.
+func (sl *SkipList[K, V]) unindexExpired(key IonKey, val IonValue) {
+	sl.unindex(*(*K)(key), []V{*(*V)(val)})
+}
+
+// reindex rebuilds every secondary index after records were added without
+// going through Insert.
+func (sl *SkipList[K, V]) reindex() {
+	for _, index := range sl.indexes {
+		index.rebuild()
+	}
+}
+
+// GetAll returns the values of every duplicate of key in insertion order.
+func (sl *SkipList[K, V]) GetAll(key K) []V {
+	vals := sl.values(key)
+	if len(vals) == 0 {
+		sl.LastStatus = IonStatus{ErrItemNotFound, 0}
+	} else {
+		sl.LastStatus = IonStatus{ErrOk, IonResultCount(len(vals))}
+	}
+	return vals
+}
+
+// matchValue picks the first duplicate equal to target. Values are compared
+// as V, so strings and other values that hold pointers match by content.
+func (sl *SkipList[K, V]) matchValue(target V) ionDuplicateMatch {
+	return func(p int, val IonValue) bool {
+		return any(*(*V)(val)) == any(target)
+	}
+}
+
+// DeleteValue removes the first record of key whose value is val and keeps
+// the other duplicates. V must be comparable, ErrUnableToConvert otherwise.
+func (sl *SkipList[K, V]) DeleteValue(key K, val V) IonStatus {
+	if !valueComparable[V]() {
+		sl.LastStatus = IonStatus{ErrUnableToConvert, 0}
+		return sl.LastStatus
+	}
+	ionKey := (IonKey)(unsafe.Pointer(&key))
+	status := dictDeleteOne(&(sl.dict), ionKey, sl.matchValue(val))
+	if status.Err == ErrOk {
+		sl.unindex(key, []V{val})
+	}
+	sl.LastStatus = status
+	return status
+}
+
+// UpdateAt sets the value of the duplicate of key at pos, counting from 0 in
+// insertion order.
+func (sl *SkipList[K, V]) UpdateAt(key K, pos int, val V) IonStatus {
+	var oldVals []V
+	if len(sl.indexes) > 0 {
+		oldVals = sl.values(key)
+	}
+	ionKey := (IonKey)(unsafe.Pointer(&key))
+	ionVal := (IonValue)(unsafe.Pointer(&val))
+	status := dictUpdateOne(&(sl.dict), ionKey, dictMatchPosition(pos), ionVal)
+	if status.Err == ErrOk && len(sl.indexes) > 0 {
+		sl.unindex(key, oldVals[pos:pos+1])
+		for _, index := range sl.indexes {
+			index.indexInsert(key, val)
+		}
+	}
+	sl.LastStatus = status
+	return status
+}
+
+// UpdateValue sets the value of the first duplicate of key whose value is
+// oldVal. V must be comparable, ErrUnableToConvert otherwise.
+func (sl *SkipList[K, V]) UpdateValue(key K, oldVal V, newVal V) IonStatus {
+	if !valueComparable[V]() {
+		sl.LastStatus = IonStatus{ErrUnableToConvert, 0}
+		return sl.LastStatus
+	}
+	ionKey := (IonKey)(unsafe.Pointer(&key))
+	ionNewVal := (IonValue)(unsafe.Pointer(&newVal))
+	status := dictUpdateOne(&(sl.dict), ionKey, sl.matchValue(oldVal), ionNewVal)
+	if status.Err == ErrOk {
+		sl.unindex(key, []V{oldVal})
+		for _, index := range sl.indexes {
+			index.indexInsert(key, newVal)
+		}
+	}
 	sl.LastStatus = status
 	return status
 }
@@ -77,7 +284,14 @@ func (sl *SkipList[K, V]) DestroyDictionary(id IonDictionaryID) IonErr {
 }
 
 func (sl *SkipList[K, V]) Open(configInfo IonDictionaryConfigInfo) IonErr {
+	if configInfo.codec != nil && valueHasPointers[V]() {
+		sl.LastStatus.Err = ErrUnableToConvert
+		return ErrUnableToConvert
+	}
 	err := dictOpen(&(sl.handler), &(sl.dict), &configInfo)
+	if err == ErrOk && len(sl.indexes) > 0 {
+		sl.dict.instance.expired = sl.unindexExpired
+	}
 	sl.keyType = configInfo.kType
 	sl.keySize = configInfo.kSize
 	sl.valSize = configInfo.vSize
@@ -86,6 +300,49 @@ func (sl *SkipList[K, V]) Open(configInfo IonDictionaryConfigInfo) IonErr {
 	return err
 }
 
+// Config returns the configuration the dictionary can be opened again with.
+func (sl *SkipList[K, V]) Config() IonDictionaryConfigInfo {
+	skipList := (*ionSkipList)(unsafe.Pointer(sl.dict.instance))
+	conf := dictConfig(&(sl.dict))
+	conf.dictSize = sl.dictSize
+	conf.pnum = skipList.pnum
+	conf.pden = skipList.pden
+	conf.seed = skipList.seed
+	conf.seeded = true
+	if skipList.arena != nil {
+		conf.slabNodes = skipList.arena.nodesPerSlab
+	}
+	return conf
+}
+
+// MemoryUsage reports the records the skip list holds and the bytes their
+// nodes, keys, values and links take, the head included.
+func (sl *SkipList[K, V]) MemoryUsage() IonMemoryUsage {
+	skipList := (*ionSkipList)(unsafe.Pointer(sl.dict.instance))
+	return IonMemoryUsage{Records: skipList.records, Bytes: skipList.bytes}
+}
+
+// CompressionStats reports how much the value codec saves on the values
+// the skip list holds. It is zero without a codec.
+func (sl *SkipList[K, V]) CompressionStats() IonCompressionStats {
+	return (*ionSkipList)(unsafe.Pointer(sl.dict.instance)).compressed
+}
+
+// SkipListStats describes the shape of a skip list.
+type SkipListStats struct {
+	Records int
+	// Levels[h] is how many nodes are h+1 levels tall.
+	Levels []int
+	// AvgSearchPath is the mean number of links a search follows to reach
+	// the first record of a key, counting one per level it drops.
+	AvgSearchPath float64
+}
+
+// Stats walks the skip list and reports its level histogram and search cost.
+func (sl *SkipList[K, V]) Stats() SkipListStats {
+	return slStats((*ionSkipList)(unsafe.Pointer(sl.dict.instance)))
+}
+
 func (sl *SkipList[K, V]) Close() IonErr {
 	err := dictClose(&(sl.dict))
 	sl.LastStatus.Err = err
@@ -115,6 +372,156 @@ func (sl *SkipList[K, V]) AllRecords() *Cursor[K, V] {
 	return NewCursor[K, V](&(sl.dict), predicate)
 }
 
+// Export writes every record to w in the portable export format. Values, and
+// keys other than null terminated strings, that hold pointers can't be
+// written portably and give ErrUnableToConvert.
+func (sl *SkipList[K, V]) Export(w io.Writer) IonErr {
+	if !sl.portable() {
+		sl.LastStatus.Err = ErrUnableToConvert
+		return ErrUnableToConvert
+	}
+	err := dictExport(&(sl.dict), w)
+	sl.LastStatus.Err = err
+	return err
+}
+
+// portable reports whether records of the skip list can go through the
+// export format.
+func (sl *SkipList[K, V]) portable() bool {
+	if valueHasPointers[V]() {
+		return false
+	}
+	return sl.keyType == KeyTypeNullTerminatedString || !valueHasPointers[K]()
+}
+
+// Import inserts every record of an export stream read from r. It gives
+// ErrUnableToConvert where Export does.
+func (sl *SkipList[K, V]) Import(r io.Reader) IonErr {
+	if !sl.portable() {
+		sl.LastStatus.Err = ErrUnableToConvert
+		return ErrUnableToConvert
+	}
+	err := dictImport(&(sl.dict), r)
+	sl.reindex()
+	sl.LastStatus.Err = err
+	return err
+}
+
+// BulkLoad inserts the records returned by next until it reports false.
+// Keys must come in ascending order, otherwise loading stops with
+// ErrSortedOrderViolation and the records before stay inserted. The
+// dictionary does not have to be empty: keys past its largest one are
+// appended, the others are inserted where they belong.
+func (sl *SkipList[K, V]) BulkLoad(next func() (K, V, bool)) IonStatus {
+	status := dictBulkLoad(&(sl.dict), func(record *IonRecord) bool {
+		key, val, ok := next()
+		if !ok {
+			return false
+		}
+		record.key = IonKey(unsafe.Pointer(&key))
+		record.value = IonValue(unsafe.Pointer(&val))
+		return true
+	})
+	sl.reindex()
+	sl.LastStatus = status
+	return status
+}
+
+// InsertBatch inserts keys[i] with vals[i] for every i and returns the
+// status of each insert in the order of keys. If keys and vals differ in
+// length nothing is inserted and every key gets ErrOutOfBounds.
+func (sl *SkipList[K, V]) InsertBatch(keys []K, vals []V) []IonStatus {
+	if len(vals) != len(keys) {
+		statuses := make([]IonStatus, len(keys))
+		for i := range statuses {
+			statuses[i] = IonStatus{ErrOutOfBounds, 0}
+		}
+		sl.LastStatus = IonStatus{ErrOutOfBounds, 0}
+		return statuses
+	}
+	records := make([]IonRecord, len(keys))
+	for i := range keys {
+		records[i].key = IonKey(unsafe.Pointer(&keys[i]))
+		records[i].value = IonValue(unsafe.Pointer(&vals[i]))
+	}
+	statuses := dictBatch(&(sl.dict), batchInsert, records)
+	for i := range statuses {
+		if statuses[i].Err == ErrOk {
+			for _, index := range sl.indexes {
+				index.indexInsert(keys[i], vals[i])
+			}
+		}
+	}
+	sl.LastStatus = slBatchSummary(statuses)
+	return statuses
+}
+
+// GetBatch looks every key up and returns the values and statuses in the
+// order of keys. A value whose status is not ErrOk is the zero value.
+func (sl *SkipList[K, V]) GetBatch(keys []K) ([]V, []IonStatus) {
+	var zero V
+	stride := uintptr(sl.dict.instance.record.valueSize)
+	if unsafe.Sizeof(zero) > stride {
+		stride = unsafe.Sizeof(zero)
+	}
+	buf := make([]IonByte, stride*uintptr(len(keys))+1)
+	records := make([]IonRecord, len(keys))
+	for i := range keys {
+		records[i].key = IonKey(unsafe.Pointer(&keys[i]))
+		records[i].value = IonValue(unsafe.Pointer(&buf[uintptr(i)*stride]))
+	}
+	statuses := dictBatch(&(sl.dict), batchGet, records)
+
+	vals := make([]V, len(keys))
+	for i := range vals {
+		if statuses[i].Err == ErrOk {
+			vals[i] = *((*V)(records[i].value))
+		}
+	}
+	sl.LastStatus = slBatchSummary(statuses)
+	return vals, statuses
+}
+
+// DeleteBatch deletes every record of each key and returns the statuses in
+// the order of keys.
+func (sl *SkipList[K, V]) DeleteBatch(keys []K) []IonStatus {
+	var oldVals [][]V
+	if len(sl.indexes) > 0 {
+		oldVals = make([][]V, len(keys))
+		for i := range keys {
+			oldVals[i] = sl.values(keys[i])
+		}
+	}
+	records := make([]IonRecord, len(keys))
+	for i := range keys {
+		records[i].key = IonKey(unsafe.Pointer(&keys[i]))
+	}
+	statuses := dictBatch(&(sl.dict), batchDelete, records)
+	if len(sl.indexes) > 0 {
+		// a key repeated in the batch is only deleted by its first occurrence.
+		for i := range statuses {
+			if statuses[i].Err == ErrOk && statuses[i].ResCnt > 0 {
+				sl.unindex(keys[i], oldVals[i])
+			}
+		}
+	}
+	sl.LastStatus = slBatchSummary(statuses)
+	return statuses
+}
+
+// slBatchSummary folds batch statuses into one: the first error, if any, and
+// the total result count.
+func slBatchSummary(statuses []IonStatus) IonStatus {
+	summary := IonStatus{ErrOk, 0}
+	for _, status := range statuses {
+		if summary.Err == ErrOk {
+			summary.Err = status.Err
+		}
+		summary.ResCnt += status.ResCnt
+	}
+	return summary
+}
+
 type slDictHandler struct{}
 
 func SldictInit(handler *IonDictionaryHandler) {
@@ -126,13 +533,18 @@ func (slHandler slDictHandler) insert(dict *IonDictionary, key IonKey, val IonVa
 	return slInsert((*ionSkipList)(unsafe.Pointer(dict.instance)), key, val)
 }
 
+func (slHandler slDictHandler) insertTTL(dict *IonDictionary, key IonKey, val IonValue, ttl time.Duration) IonStatus {
+	skipList := (*ionSkipList)(unsafe.Pointer(dict.instance))
+	return slInsertExpiring(skipList, key, val, slExpiry(skipList, ttl))
+}
+
 func (slHandler slDictHandler) createDictionary(id IonDictionaryID, kType IonKeyType, kSize IonKeySize, vSize IonValueSize, dictSize IonDictionarySize, compare IonDictionaryCompare, handler *IonDictionaryHandler, dict *IonDictionary) IonErr {
 	_ = id
 	var skipList ionSkipList
 	dict.instance = (*IonDictionaryParent)(unsafe.Pointer(&skipList))
 
 	dict.instance.compare = compare
-	dict.instance.kType = DictionaryTypeSkipList
+	dict.instance.dictType = DictionaryTypeSkipList
 
 	pnum := 1
 	pden := 4
@@ -163,16 +575,22 @@ func slDictNext(cursor *IonDictCursor, record *IonRecord) IonCursorStatus {
 	} else if cursor.status == csEndOfResults {
 		return cursor.status
 	} else if cursor.status == csCursorInitialized || cursor.status == csCursorActive {
-		if cursor.status == csCursorActive {
-			if slCursor.current == nil || testPredicate(cursor, slCursor.current.key) == false {
-				cursor.status = csEndOfResults
-				return cursor.status
-			}
-		} else {
-			cursor.status = csCursorActive
+		skipList := (*ionSkipList)(unsafe.Pointer(cursor.dict.instance))
+		for slCursor.current != nil && slExpired(skipList, slCursor.current) {
+			expired := slCursor.current
+			slCursor.current = slCursor.current.next[0]
+			slUnlinkExpired(skipList, expired)
+		}
+		if slCursor.current == nil || testPredicate(cursor, slCursor.current.key) == false {
+			cursor.status = csEndOfResults
+			return cursor.status
 		}
+		cursor.status = csCursorActive
 		memcpy(unsafe.Pointer(record.key), unsafe.Pointer(slCursor.current.key), uintptr(cursor.dict.instance.record.keySize))
-		memcpy(unsafe.Pointer(record.value), unsafe.Pointer(slCursor.current.val), uintptr(cursor.dict.instance.record.valueSize))
+		if slReadValue(skipList, slCursor.current, record.value) != ErrOk {
+			cursor.status = csInvalidCursor
+			return cursor.status
+		}
 
 		slCursor.current = slCursor.current.next[0]
 		return cursor.status
@@ -242,6 +660,7 @@ func (slHandler slDictHandler) find(dict *IonDictionary, predicate IonPredicate,
 			return ErrOk
 		}
 	case *IonPredicateAllRecords:
+		(*cursor).predicate = new(IonPredicateAllRecords)
 		slCursor := (*ionSlDictCursor)(unsafe.Pointer(*cursor))
 		skipList := (*ionSkipList)(unsafe.Pointer(dict.instance))
 		if skipList.head.next[0] == nil {
@@ -278,12 +697,67 @@ func (slHandler slDictHandler) closeDictionary(dict *IonDictionary) IonErr {
 	return ErrNotImplemented
 }
 
+func (slHandler slDictHandler) batch(dict *IonDictionary, op ionBatchOp, records []IonRecord, statuses []IonStatus) IonErr {
+	skipList := (*ionSkipList)(unsafe.Pointer(dict.instance))
+	finger := slNewFinger(skipList)
+	for i := range records {
+		switch op {
+		case batchInsert:
+			statuses[i] = slFingerInsert(skipList, finger, records[i].key, records[i].value)
+		case batchGet:
+			statuses[i] = slFingerGet(skipList, finger, records[i].key, records[i].value)
+		case batchDelete:
+			statuses[i] = slFingerDelete(skipList, finger, records[i].key)
+		}
+	}
+	return ErrOk
+}
+
+func (slHandler slDictHandler) updateOne(dict *IonDictionary, key IonKey, match ionDuplicateMatch, val IonValue) IonStatus {
+	return slUpdateOne((*ionSkipList)(unsafe.Pointer(dict.instance)), key, match, val)
+}
+
+func (slHandler slDictHandler) removeOne(dict *IonDictionary, key IonKey, match ionDuplicateMatch) IonStatus {
+	return slDeleteOne((*ionSkipList)(unsafe.Pointer(dict.instance)), key, match)
+}
+
+func (slHandler slDictHandler) dump(dict *IonDictionary, w io.Writer, format IonKeyFormatter) IonErr {
+	return slDump((*ionSkipList)(unsafe.Pointer(dict.instance)), w, format)
+}
+
+func (slHandler slDictHandler) writeDot(dict *IonDictionary, w io.Writer, format IonKeyFormatter) IonErr {
+	return slWriteDot((*ionSkipList)(unsafe.Pointer(dict.instance)), w, format)
+}
+
+func (slHandler slDictHandler) compact(dict *IonDictionary, stop func() bool) (int, IonErr) {
+	return slCompact((*ionSkipList)(unsafe.Pointer(dict.instance)), stop)
+}
+
+func (slHandler slDictHandler) bulkLoad(dict *IonDictionary, next func(record *IonRecord) bool) IonStatus {
+	return slBulkLoad((*ionSkipList)(unsafe.Pointer(dict.instance)), next)
+}
+
 type ionSkipList struct {
 	super     IonDictionaryParent
 	head      *ionSlNode
 	maxheight ionSlLevel
 	pnum      int
 	pden      int
+	// node heights are drawn from a splitmix64 generator started at seed.
+	seed     int64
+	rngState uint64
+	// nil unless nodes come from slabs.
+	arena *ionSlArena
+	// scratch space for the predecessors an insert links to.
+	update []*ionSlNode
+	rank   []int
+	// what the nodes hold, checked against the dictionary's budgets.
+	records int
+	bytes   int
+	// values held encoded by the codec, and a value decoded to match
+	// duplicates against.
+	compressed IonCompressionStats
+	plain      []byte
 }
 
 type ionSlLevel int
@@ -293,6 +767,11 @@ type ionSlNode struct {
 	val    IonValue
 	height ionSlLevel
 	next   []*ionSlNode
+	// width[h] is how many level 0 steps next[h] covers. A nil next[h]
+	// covers the steps to the last node, so the last node's widths are 0.
+	width []int
+	// UnixNano time the record expires at, 0 if it never does.
+	expires int64
 }
 
 type ionSlDictCursor struct {
@@ -307,6 +786,7 @@ func slInitialize(skipList *ionSkipList, kType IonKeyType, kSize IonKeySize, vSi
 	skipList.maxheight = maxheight
 	skipList.pnum = pnum
 	skipList.pden = pden
+	slSeed(skipList, time.Now().UnixNano())
 
 	if slDebug {
 		println("skipList super record kSize :", kSize)
@@ -320,6 +800,11 @@ func slInitialize(skipList *ionSkipList, kType IonKeyType, kSize IonKeySize, vSi
 
 	tmpArray := make([]*ionSlNode, skipList.maxheight)
 	skipList.head.next = tmpArray
+	skipList.head.width = make([]int, skipList.maxheight)
+	skipList.update = make([]*ionSlNode, skipList.maxheight)
+	skipList.rank = make([]int, skipList.maxheight)
+	skipList.records = 0
+	skipList.bytes = int(unsafe.Sizeof(ionSlNode{})) + slLinkBytes(skipList.maxheight)
 
 	skipList.head.height = maxheight - 1
 	skipList.head.key = nil
@@ -332,46 +817,478 @@ func slInitialize(skipList *ionSkipList, kType IonKeyType, kSize IonKeySize, vSi
 	return ErrOk
 }
 
+// slApplyConfig applies the level generation options of conf to a skip list
+// that is still empty.
+func slApplyConfig(skipList *ionSkipList, conf *IonDictionaryConfigInfo) IonErr {
+	if conf.pnum != 0 || conf.pden != 0 {
+		if conf.pnum <= 0 || conf.pden <= conf.pnum {
+			return ErrOutOfBounds
+		}
+		skipList.pnum = conf.pnum
+		skipList.pden = conf.pden
+	}
+	if conf.seeded {
+		slSeed(skipList, conf.seed)
+	}
+	if conf.slabNodes > 0 {
+		skipList.arena = slNewArena(conf.slabNodes, skipList.super.record.keySize, skipList.super.record.valueSize, skipList.maxheight, skipList.pnum, skipList.pden)
+	}
+	return ErrOk
+}
+
 func slInsert(skipList *ionSkipList, key IonKey, val IonValue) IonStatus {
+	return slInsertExpiring(skipList, key, val, slExpiry(skipList, skipList.super.ttl))
+}
+
+// slInsertExpiring inserts a record that expires at the UnixNano time
+// expires, or never if it is 0.
+func slInsertExpiring(skipList *ionSkipList, key IonKey, val IonValue, expires int64) IonStatus {
+	kSize := skipList.super.record.keySize
+	update := skipList.update
+	rank := skipList.rank
+
+	// stop after the last node not greater than key, so a duplicate goes to
+	// the end of its chain.
+	cursor := skipList.head
+	pos := 0
+	var h ionSlLevel
+	for h = skipList.head.height; h >= 0; h-- {
+		for cursor.next[h] != nil && skipList.super.compare(cursor.next[h].key, key, kSize) <= 0 {
+			pos += cursor.width[h]
+			cursor = cursor.next[h]
+		}
+		update[h] = cursor
+		rank[h] = pos
+	}
+
+	isDuplicate := cursor != skipList.head && skipList.super.compare(cursor.key, key, kSize) == 0
+	if isDuplicate && skipList.super.uniqueKeys {
+		if !slExpired(skipList, cursor) {
+			return IonStatus{ErrDuplicateKey, 0}
+		}
+		slUnlinkExpired(skipList, cursor)
+		return slInsertExpiring(skipList, key, val, expires)
+	}
+
+	height := ionSlLevel(0)
+	if !isDuplicate {
+		height = slGenLevel(skipList)
+	}
+	newNode, err := slNewNode(skipList, key, val, height, expires)
+	if err != ErrOk {
+		return IonStatus{err, 0}
+	}
+	slLinkNode(skipList, update, rank, newNode)
+	return IonStatus{ErrOk, 1}
+}
+
+// slNewNode allocates a node of the given height holding copies of key and
+// val, or fails with ErrMaxCapacity if that would go over a budget.
+func slNewNode(skipList *ionSkipList, key IonKey, val IonValue, height ionSlLevel, expires int64) (*ionSlNode, IonErr) {
 	kSize := skipList.super.record.keySize
 	vSize := skipList.super.record.valueSize
+	size := slNodeBytes(skipList, height)
+	var stored []byte
+	if skipList.super.codec != nil {
+		stored = dictEncodeValue(&(skipList.super), val)
+		size += len(stored) - int(vSize)
+	}
+	if budget := skipList.super.recordBudget; budget > 0 && skipList.records+1 > budget {
+		return nil, ErrMaxCapacity
+	}
+	if budget := skipList.super.memoryBudget; budget > 0 && skipList.bytes+size > budget {
+		return nil, ErrMaxCapacity
+	}
+	skipList.records++
+	skipList.bytes += size
 
-	newNode := new(ionSlNode)
-	newNode.key = IonKey(alloc(uintptr(kSize), nil))
-	newNode.val = IonValue(alloc(uintptr(vSize), nil))
+	var newNode *ionSlNode
+	if skipList.arena != nil {
+		newNode = skipList.arena.node(height)
+	} else {
+		newNode = new(ionSlNode)
+		newNode.key = IonKey(alloc(uintptr(kSize), nil))
+		if stored == nil {
+			newNode.val = IonValue(alloc(uintptr(vSize), nil))
+		}
+		newNode.height = height
+		newNode.next = make([]*ionSlNode, height+1)
+		newNode.width = make([]int, height+1)
+	}
 	memcpy(unsafe.Pointer(newNode.key), unsafe.Pointer(key), uintptr(kSize))
-	memcpy(unsafe.Pointer(newNode.val), unsafe.Pointer(val), uintptr(vSize))
+	if stored != nil {
+		newNode.val = IonValue(unsafe.Pointer(&stored[0]))
+		slCountStored(skipList, len(stored))
+	} else {
+		memcpy(unsafe.Pointer(newNode.val), unsafe.Pointer(val), uintptr(vSize))
+	}
+	newNode.expires = expires
+	return newNode, ErrOk
+}
 
-	duplicate := slFindNode(skipList, key)
-	if duplicate.key != nil && skipList.super.compare(duplicate.key, key, kSize) == 0 {
-		newNode.height = 0
-		newNode.next = make([]*ionSlNode, newNode.height+1)
-		for duplicate.next[0] != nil && skipList.super.compare(duplicate.next[0].key, key, kSize) == 0 {
-			duplicate = duplicate.next[0]
-		}
-		newNode.next[0] = duplicate.next[0]
-		duplicate.next[0] = newNode
+// slCountStored adds a value stored in size bytes to the compression stats,
+// or takes it off them if size is negative.
+func slCountStored(skipList *ionSkipList, size int) {
+	vSize := int(skipList.super.record.valueSize)
+	if size < 0 {
+		skipList.compressed.Values--
+		skipList.compressed.RawBytes -= vSize
 	} else {
-		newNode.height = slGenLevel(skipList)
+		skipList.compressed.Values++
+		skipList.compressed.RawBytes += vSize
+	}
+	skipList.compressed.StoredBytes += size
+}
 
-		newNode.next = make([]*ionSlNode, newNode.height+1)
+// slStoredBytes is how many bytes more or fewer than the value size the
+// stored value of node takes.
+func slStoredBytes(skipList *ionSkipList, node *ionSlNode) int {
+	if skipList.super.codec == nil {
+		return 0
+	}
+	_, size := dictStoredValue(node.val)
+	return size - int(skipList.super.record.valueSize)
+}
 
-		cursor := skipList.head
-		var h ionSlLevel
-		for h = skipList.head.height; h >= 0; h-- {
-			for cursor.next[h] != nil && skipList.super.compare(key, cursor.next[h].key, kSize) >= 0 {
-				cursor = cursor.next[h]
+// slReadValue copies the value of node to val, decoding it if the
+// dictionary has a codec.
+func slReadValue(skipList *ionSkipList, node *ionSlNode, val IonValue) IonErr {
+	if skipList.super.codec == nil {
+		memcpy(unsafe.Pointer(val), unsafe.Pointer(node.val), uintptr(skipList.super.record.valueSize))
+		return ErrOk
+	}
+	return dictDecodeValue(&(skipList.super), node.val, val)
+}
+
+// slPlainValue returns the value of node as it was written. A decoded value
+// only lasts until the next call.
+func slPlainValue(skipList *ionSkipList, node *ionSlNode) IonValue {
+	if skipList.super.codec == nil {
+		return node.val
+	}
+	if len(skipList.plain) == 0 {
+		skipList.plain = make([]byte, skipList.super.record.valueSize+1)
+	}
+	val := IonValue(unsafe.Pointer(&skipList.plain[0]))
+	slReadValue(skipList, node, val)
+	return val
+}
+
+// slWriteValue replaces the value of node with val.
+func slWriteValue(skipList *ionSkipList, node *ionSlNode, val IonValue) {
+	if skipList.super.codec == nil {
+		memcpy(unsafe.Pointer(node.val), unsafe.Pointer(val), uintptr(skipList.super.record.valueSize))
+		return
+	}
+	old := slStoredBytes(skipList, node)
+	slCountStored(skipList, -(old + int(skipList.super.record.valueSize)))
+	stored := dictEncodeValue(&(skipList.super), val)
+	node.val = IonValue(unsafe.Pointer(&stored[0]))
+	slCountStored(skipList, len(stored))
+	skipList.bytes += slStoredBytes(skipList, node) - old
+}
+
+// slValueFits tells whether writing val over node, and over every live
+// duplicate after it when all is set, keeps the dictionary within its memory
+// budget. Only a codec changes how many bytes a value takes.
+func slValueFits(skipList *ionSkipList, node *ionSlNode, val IonValue, all bool) bool {
+	budget := skipList.super.memoryBudget
+	if budget <= 0 || skipList.super.codec == nil {
+		return true
+	}
+	kSize := skipList.super.record.keySize
+	size := len(dictEncodeValue(&(skipList.super), val)) - int(skipList.super.record.valueSize)
+	growth := 0
+	for cursor := node; cursor != nil && skipList.super.compare(cursor.key, node.key, kSize) == 0; cursor = cursor.next[0] {
+		if !slExpired(skipList, cursor) {
+			growth += size - slStoredBytes(skipList, cursor)
+		}
+		if !all {
+			break
+		}
+	}
+	return skipList.bytes+growth <= budget
+}
+
+// slNodeBytes is what a node of the given height costs: the node itself, a
+// copy of the key and the value, and its next and width arrays.
+func slNodeBytes(skipList *ionSkipList, height ionSlLevel) int {
+	return int(unsafe.Sizeof(ionSlNode{})) + skipList.super.record.keySize + int(skipList.super.record.valueSize) + slLinkBytes(height+1)
+}
+
+func slLinkBytes(levels ionSlLevel) int {
+	return int(levels) * int(unsafe.Sizeof((*ionSlNode)(nil))+unsafe.Sizeof(int(0)))
+}
+
+// slReleaseNode takes a node that left the skip list off its budgets and
+// hands it back to the arena, if there is one.
+func slReleaseNode(skipList *ionSkipList, node *ionSlNode) {
+	skipList.records--
+	stored := slStoredBytes(skipList, node)
+	skipList.bytes -= slNodeBytes(skipList, node.height) + stored
+	if skipList.super.codec != nil {
+		slCountStored(skipList, -(stored + int(skipList.super.record.valueSize)))
+	}
+	if skipList.arena != nil {
+		skipList.arena.release(node)
+	}
+}
+
+// slLinkNode links node right after update[0]. update[h] is the last node on
+// level h before the new position and rank[h] its position, counting the
+// first node as 1.
+func slLinkNode(skipList *ionSkipList, update []*ionSlNode, rank []int, node *ionSlNode) {
+	var h ionSlLevel
+	for h = 0; h <= skipList.head.height; h++ {
+		if h > node.height {
+			update[h].width[h]++
+			continue
+		}
+		node.next[h] = update[h].next[h]
+		node.width[h] = update[h].width[h] - (rank[0] - rank[h])
+		update[h].next[h] = node
+		update[h].width[h] = rank[0] - rank[h] + 1
+	}
+	slDebugCheck(skipList)
+}
+
+// slUnlinkAt takes node out of the skip list, update[h] being the last node
+// on level h before it. The next pointers of node are left alone.
+func slUnlinkAt(skipList *ionSkipList, update []*ionSlNode, node *ionSlNode) {
+	var h ionSlLevel
+	for h = 0; h <= skipList.head.height; h++ {
+		if h > node.height {
+			update[h].width[h]--
+			continue
+		}
+		update[h].width[h] += node.width[h] - 1
+		update[h].next[h] = node.next[h]
+	}
+	slReleaseNode(skipList, node)
+	slDebugCheck(skipList)
+}
+
+// slBulkLoad appends sorted records to the end of the skip list, keeping the
+// last node of every level so each record is linked without a search.
+// Records below the largest key already in the skip list go in by finger
+// search instead, so the input only has to be sorted in itself.
+func slBulkLoad(skipList *ionSkipList, next func(record *IonRecord) bool) IonStatus {
+	kSize := skipList.super.record.keySize
+	status := IonStatus{ErrOk, 0}
+	defer slDebugCheck(skipList)
+
+	last := make([]*ionSlNode, skipList.head.height+1)
+	lastRank := make([]int, skipList.head.height+1)
+	count := slTail(skipList, last, lastRank)
+	tailMoved := false
+	var finger slFinger
+
+	expires := slExpiry(skipList, skipList.super.ttl)
+	prevKey := IonKey(alloc(uintptr(kSize), nil))
+	var record IonRecord
+	for next(&record) {
+		if status.ResCnt > 0 && skipList.super.compare(record.key, prevKey, kSize) < 0 {
+			status.Err = ErrSortedOrderViolation
+			return status
+		}
+		memcpy(unsafe.Pointer(prevKey), unsafe.Pointer(record.key), uintptr(kSize))
+
+		if last[0].key != nil && skipList.super.compare(record.key, last[0].key, kSize) < 0 {
+			if finger.node == nil {
+				finger = slNewFinger(skipList)
 			}
+			insertStatus := slFingerInsert(skipList, finger, record.key, record.value)
+			if insertStatus.Err != ErrOk {
+				status.Err = insertStatus.Err
+				return status
+			}
+			tailMoved = true
+			status.ResCnt++
+			continue
+		}
+		if tailMoved {
+			count = slTail(skipList, last, lastRank)
+			tailMoved = false
+		}
 
-			if h <= newNode.height {
-				newNode.next[h] = cursor.next[h]
-				cursor.next[h] = newNode
+		duplicate := last[0].key != nil && skipList.super.compare(record.key, last[0].key, kSize) == 0
+		if duplicate && skipList.super.uniqueKeys {
+			status.Err = ErrDuplicateKey
+			return status
+		}
+
+		height := ionSlLevel(0)
+		if !duplicate {
+			height = slGenLevel(skipList)
+		}
+		newNode, err := slNewNode(skipList, record.key, record.value, height, expires)
+		if err != ErrOk {
+			status.Err = err
+			return status
+		}
+		count++
+		var h ionSlLevel
+		for h = 0; h <= skipList.head.height; h++ {
+			if h > newNode.height {
+				last[h].width[h]++
+				continue
 			}
+			last[h].next[h] = newNode
+			last[h].width[h] = count - lastRank[h]
+			last[h] = newNode
+			lastRank[h] = count
 		}
+		status.ResCnt++
 	}
+	return status
+}
+
+// slTail fills last with the last node of every level and lastRank with
+// their positions, and returns how many nodes the skip list has.
+func slTail(skipList *ionSkipList, last []*ionSlNode, lastRank []int) int {
+	cursor := skipList.head
+	count := 0
+	var h ionSlLevel
+	for h = skipList.head.height; h >= 0; h-- {
+		for cursor.next[h] != nil {
+			count += cursor.width[h]
+			cursor = cursor.next[h]
+		}
+		last[h] = cursor
+		lastRank[h] = count
+	}
+	return count
+}
+
+// slFinger holds, for every level, the last node whose key is smaller than
+// the previously searched key and its position. Searching for a key that is
+// not smaller than the previous one can start from it instead of from the head.
+type slFinger struct {
+	node []*ionSlNode
+	rank []int
+}
+
+func slNewFinger(skipList *ionSkipList) slFinger {
+	finger := slFinger{
+		node: make([]*ionSlNode, skipList.head.height+1),
+		rank: make([]int, skipList.head.height+1),
+	}
+	for h := range finger.node {
+		finger.node[h] = skipList.head
+	}
+	return finger
+}
+
+// slFingerSearch moves the finger to the predecessors of key, which must not
+// be smaller than the key the finger was last moved to. It climbs from level
+// 0 only as far as the finger still has to move right, then searches down
+// from there.
+func slFingerSearch(skipList *ionSkipList, finger slFinger, key IonKey) {
+	kSize := skipList.super.record.keySize
+	top := ionSlLevel(len(finger.node) - 1)
+	h := ionSlLevel(0)
+	for h < top {
+		next := finger.node[h+1].next[h+1]
+		if next == nil || skipList.super.compare(next.key, key, kSize) >= 0 {
+			break
+		}
+		h++
+	}
+
+	cursor := finger.node[h]
+	pos := finger.rank[h]
+	for ; h >= 0; h-- {
+		for cursor.next[h] != nil && skipList.super.compare(cursor.next[h].key, key, kSize) < 0 {
+			pos += cursor.width[h]
+			cursor = cursor.next[h]
+		}
+		finger.node[h] = cursor
+		finger.rank[h] = pos
+	}
+}
+
+func slFingerInsert(skipList *ionSkipList, finger slFinger, key IonKey, val IonValue) IonStatus {
+	kSize := skipList.super.record.keySize
+	slFingerSearch(skipList, finger, key)
+
+	first := finger.node[0].next[0]
+	isDuplicate := first != nil && skipList.super.compare(first.key, key, kSize) == 0
+	if isDuplicate && skipList.super.uniqueKeys {
+		if !slExpired(skipList, first) {
+			return IonStatus{ErrDuplicateKey, 0}
+		}
+		slUnlinkExpired(skipList, first)
+		isDuplicate = false
+	}
+	expires := slExpiry(skipList, skipList.super.ttl)
+
+	if !isDuplicate {
+		newNode, err := slNewNode(skipList, key, val, slGenLevel(skipList), expires)
+		if err != ErrOk {
+			return IonStatus{err, 0}
+		}
+		slLinkNode(skipList, finger.node, finger.rank, newNode)
+		return IonStatus{ErrOk, 1}
+	}
+
+	// a duplicate goes after the last one of its chain. Above level 0 the
+	// link passing over it starts at the first node if that is tall enough.
+	update := make([]*ionSlNode, len(finger.node))
+	rank := make([]int, len(finger.node))
+	for h := range update {
+		if ionSlLevel(h) <= first.height {
+			update[h], rank[h] = first, finger.rank[0]+1
+		} else {
+			update[h], rank[h] = finger.node[h], finger.rank[h]
+		}
+	}
+	for update[0].next[0] != nil && skipList.super.compare(update[0].next[0].key, key, kSize) == 0 {
+		update[0] = update[0].next[0]
+		rank[0]++
+	}
+	newNode, err := slNewNode(skipList, key, val, 0, expires)
+	if err != ErrOk {
+		return IonStatus{err, 0}
+	}
+	slLinkNode(skipList, update, rank, newNode)
 	return IonStatus{ErrOk, 1}
 }
 
+func slFingerGet(skipList *ionSkipList, finger slFinger, key IonKey, val IonValue) IonStatus {
+	kSize := skipList.super.record.keySize
+	slFingerSearch(skipList, finger, key)
+
+	found := finger.node[0].next[0]
+	for found != nil && skipList.super.compare(found.key, key, kSize) == 0 && slExpired(skipList, found) {
+		found = found.next[0]
+	}
+	if found == nil || skipList.super.compare(found.key, key, kSize) != 0 {
+		return IonStatus{ErrItemNotFound, 0}
+	}
+	if err := slReadValue(skipList, found, val); err != ErrOk {
+		return IonStatus{err, 0}
+	}
+	return IonStatus{ErrOk, 1}
+}
+
+func slFingerDelete(skipList *ionSkipList, finger slFinger, key IonKey) IonStatus {
+	kSize := skipList.super.record.keySize
+	status := IonStatus{ErrItemNotFound, 0}
+	slFingerSearch(skipList, finger, key)
+
+	// the first node of a key is the only one that can be taller than 0,
+	// and the finger is its predecessor on every level it has. Once it is
+	// gone the finger is the predecessor of each following duplicate too.
+	for toFree := finger.node[0].next[0]; toFree != nil && skipList.super.compare(toFree.key, key, kSize) == 0; toFree = finger.node[0].next[0] {
+		slUnlinkAt(skipList, finger.node, toFree)
+		status.ResCnt++
+	}
+	if status.ResCnt > 0 {
+		status.Err = ErrOk
+	}
+	return status
+}
+
 func slDestroy(skipList *ionSkipList) IonErr {
 	cursor := skipList.head
 	var toFree *ionSlNode
@@ -385,73 +1302,210 @@ func slDestroy(skipList *ionSkipList) IonErr {
 	}
 
 	skipList.head = nil
+	skipList.arena = nil
+	skipList.records = 0
+	skipList.bytes = 0
 	return ErrOk
 }
 
 func slGet(skipList *ionSkipList, key IonKey, val IonValue) IonStatus {
 	kSize := skipList.super.record.keySize
-	vSize := skipList.super.record.valueSize
 	cursor := slFindNode(skipList, key)
 	if (cursor.key == nil) || (skipList.super.compare(cursor.key, key, kSize) != 0) {
 		return IonStatus{ErrItemNotFound, 0}
 	}
+	for cursor != nil && slExpired(skipList, cursor) {
+		cursor = cursor.next[0]
+		if cursor != nil && skipList.super.compare(cursor.key, key, kSize) != 0 {
+			cursor = nil
+		}
+	}
+	if cursor == nil {
+		return IonStatus{ErrItemNotFound, 0}
+	}
 
-	memcpy(unsafe.Pointer(val), unsafe.Pointer(cursor.val), uintptr(vSize))
+	if err := slReadValue(skipList, cursor, val); err != ErrOk {
+		return IonStatus{err, 0}
+	}
 	return IonStatus{ErrOk, 1}
 }
 
 func slUpdate(skipList *ionSkipList, key IonKey, val IonValue) IonStatus {
 	status := IonStatus{ErrUninitialized, 0}
 	kSize := skipList.super.record.keySize
-	vSize := skipList.super.record.valueSize
 	cursor := slFindNode(skipList, key)
 	if (cursor.key == nil) || (skipList.super.compare(cursor.key, key, kSize) != 0) {
-		status.Err = slInsert(skipList, key, val).Err
-		status.ResCnt = 1
-		return status
+		return slInsert(skipList, key, val)
 	}
-	for cursor != nil && skipList.super.compare(cursor.key, key, skipList.super.record.keySize) == 0 {
-		memcpy(unsafe.Pointer(cursor.val), unsafe.Pointer(val), uintptr(vSize))
-		cursor = cursor.next[0]
-		status.ResCnt++
+	// expired duplicates are dropped rather than brought back, so a key
+	// whose records all expired is inserted again.
+	if !slValueFits(skipList, cursor, val, true) {
+		return IonStatus{ErrMaxCapacity, 0}
+	}
+	expires := slExpiry(skipList, skipList.super.ttl)
+	for cursor != nil && skipList.super.compare(cursor.key, key, kSize) == 0 {
+		next := cursor.next[0]
+		if slExpired(skipList, cursor) {
+			slUnlinkExpired(skipList, cursor)
+		} else {
+			slWriteValue(skipList, cursor, val)
+			if expires != 0 {
+				cursor.expires = expires
+			}
+			status.ResCnt++
+		}
+		cursor = next
+	}
+	if status.ResCnt == 0 {
+		return slInsert(skipList, key, val)
 	}
 	status.Err = ErrOk
 	return status
 }
 
 func slDelete(skipList *ionSkipList, key IonKey) IonStatus {
+	return slFingerDelete(skipList, slNewFinger(skipList), key)
+}
+
+// slUpdateOne sets the value of the first node in the duplicate chain of key
+// that match picks. Expired duplicates are dropped on the way and do not
+// count towards positions.
+func slUpdateOne(skipList *ionSkipList, key IonKey, match ionDuplicateMatch, val IonValue) IonStatus {
+	node := slMatchOne(skipList, key, match)
+	if node == nil {
+		return IonStatus{ErrItemNotFound, 0}
+	}
+	if !slValueFits(skipList, node, val, false) {
+		return IonStatus{ErrMaxCapacity, 0}
+	}
+	slWriteValue(skipList, node, val)
+	if expires := slExpiry(skipList, skipList.super.ttl); expires != 0 {
+		node.expires = expires
+	}
+	return IonStatus{ErrOk, 1}
+}
+
+// slDeleteOne removes the first node in the duplicate chain of key that
+// match picks, passing over expired duplicates as slUpdateOne does.
+func slDeleteOne(skipList *ionSkipList, key IonKey, match ionDuplicateMatch) IonStatus {
+	node := slMatchOne(skipList, key, match)
+	if node == nil {
+		return IonStatus{ErrItemNotFound, 0}
+	}
+	slUnlinkNode(skipList, node)
+	return IonStatus{ErrOk, 1}
+}
+
+// slMatchOne returns the first live node in the duplicate chain of key that
+// match picks, or nil, unlinking the expired nodes it passes.
+func slMatchOne(skipList *ionSkipList, key IonKey, match ionDuplicateMatch) *ionSlNode {
 	kSize := skipList.super.record.keySize
-	status := IonStatus{ErrItemNotFound, 0}
-	cursor := skipList.head
-	var h ionSlLevel
-	for h = skipList.head.height; h >= 0; h-- {
-		for cursor.next[h] != nil && skipList.super.compare(cursor.next[h].key, key, kSize) < 0 {
-			cursor = cursor.next[h]
+	cursor := slFindNode(skipList, key)
+	pos := 0
+	for cursor != nil && cursor.key != nil && skipList.super.compare(cursor.key, key, kSize) == 0 {
+		next := cursor.next[0]
+		if slExpired(skipList, cursor) {
+			slUnlinkExpired(skipList, cursor)
+		} else {
+			if match(pos, slPlainValue(skipList, cursor)) {
+				return cursor
+			}
+			pos++
+		}
+		cursor = next
+	}
+	return nil
+}
+
+// slExpiry returns the UnixNano time a record written now with ttl expires
+// at, or 0 if ttl does not expire it.
+func slExpiry(skipList *ionSkipList, ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return dictNow(&(skipList.super)).Add(ttl).UnixNano()
+}
+
+func slExpired(skipList *ionSkipList, node *ionSlNode) bool {
+	return node.expires != 0 && node.expires <= dictNow(&(skipList.super)).UnixNano()
+}
+
+// slUnlinkExpired takes the expired node out of the skip list and tells the
+// dictionary about it first.
+func slUnlinkExpired(skipList *ionSkipList, node *ionSlNode) {
+	if skipList.super.expired != nil {
+		skipList.super.expired(node.key, slPlainValue(skipList, node))
+	}
+	dictBloomExpired(&(skipList.super))
+	slUnlinkNode(skipList, node)
+}
+
+// slUnlinkNode takes node out of the skip list. When node is the first of a
+// duplicate chain the next duplicate is raised to its height. The next
+// pointers of node are left alone, so a cursor standing on it can still move on.
+func slUnlinkNode(skipList *ionSkipList, node *ionSlNode) {
+	kSize := skipList.super.record.keySize
+	finger := slNewFinger(skipList)
+	slFingerSearch(skipList, finger, node.key)
+
+	first := finger.node[0].next[0]
+	if first != node {
+		prev := first
+		for prev != nil && prev.next[0] != node {
+			prev = prev.next[0]
+		}
+		if prev == nil {
+			return
+		}
+		update := make([]*ionSlNode, len(finger.node))
+		copy(update, finger.node)
+		var h ionSlLevel
+		for h = 0; h <= first.height; h++ {
+			update[h] = first
+		}
+		update[0] = prev
+		slUnlinkAt(skipList, update, node)
+		return
+	}
+
+	if dup := node.next[0]; dup != nil && skipList.super.compare(dup.key, node.key, kSize) == 0 {
+		// dup takes the place and the links of node, one step closer to
+		// what they point at, and node is left with dup's single link.
+		next, width := node.next, node.width
+		dupNext, dupWidth := dup.next, dup.width
+		next[0], width[0] = dupNext[0], dupWidth[0]
+		var h ionSlLevel
+		for h = 1; h <= node.height; h++ {
+			width[h]--
 		}
-		if (cursor.next[h] != nil) && (skipList.super.compare(cursor.next[h].key, key, kSize) == 0) {
-			oldCursor := cursor
-			for cursor.next[h] != nil && skipList.super.compare(cursor.next[h].key, key, kSize) == 0 {
-				toFree := cursor.next[h]
-				reLink := cursor.next[h]
-				linkH := reLink.height
-
-				for linkH >= 0 {
-					for cursor.next[linkH] != reLink {
-						cursor = cursor.next[linkH]
-					}
-					jump := reLink.next[linkH]
-					cursor.next[linkH] = jump
-					linkH--
-				}
-				toFree.key = nil
-				toFree.val = nil
-				toFree.next = nil
-				toFree = nil
-
-				cursor = oldCursor
-				status.ResCnt++
+		for h = 0; h <= skipList.head.height; h++ {
+			if h <= node.height {
+				finger.node[h].next[h] = dup
+			} else {
+				finger.node[h].width[h]--
 			}
-			status.Err = ErrOk
+		}
+		dup.next, dup.width, dup.height = next, width, node.height
+		node.next, node.width, node.height = dupNext, dupWidth, 0
+		node.next[0] = dup
+		slReleaseNode(skipList, node)
+		slDebugCheck(skipList)
+		return
+	}
+	slUnlinkAt(skipList, finger.node, node)
+}
+
+// slSweep removes every expired record.
+func slSweep(skipList *ionSkipList) IonStatus {
+	status := IonStatus{ErrOk, 0}
+	now := dictNow(&(skipList.super)).UnixNano()
+	cursor := skipList.head.next[0]
+	for cursor != nil {
+		node := cursor
+		cursor = cursor.next[0]
+		if node.expires != 0 && node.expires <= now {
+			slUnlinkExpired(skipList, node)
+			status.ResCnt++
 		}
 	}
 	return status
@@ -472,30 +1526,62 @@ func slFindNode(skipList *ionSkipList, key IonKey) *ionSlNode {
 	return cursor
 }
 
+func slSeed(skipList *ionSkipList, seed int64) {
+	skipList.seed = seed
+	skipList.rngState = uint64(seed)
+}
+
+// slRandom returns the next number of the splitmix64 sequence of the skip list.
+func slRandom(skipList *ionSkipList) uint64 {
+	skipList.rngState += 0x9e3779b97f4a7c15
+	z := skipList.rngState
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
 func slGenLevel(skipList *ionSkipList) ionSlLevel {
 	level := ionSlLevel(1)
-	for rand.Float32() < float32(skipList.pnum)/float32(skipList.pden) && level < skipList.maxheight {
+	for slRandom(skipList)%uint64(skipList.pden) < uint64(skipList.pnum) && level < skipList.maxheight {
 		level++
 	}
 	return level - 1
 }
 
-func printSkipList[V any](skipList *ionSkipList) {
-	cursor := skipList.head
-	for cursor.next[0] != nil {
-		level := cursor.next[0].height + 1
-
-		if skipList.super.kType == KeyTypeNumericSigned {
-			key := *((*int)(cursor.next[0].key))
-			val := *((*V)(cursor.next[0].val))
+func slStats(skipList *ionSkipList) SkipListStats {
+	kSize := skipList.super.record.keySize
+	stats := SkipListStats{Levels: make([]int, skipList.maxheight)}
+	keys := 0
+	links := 0
+	prev := skipList.head
+	for node := skipList.head.next[0]; node != nil; prev, node = node, node.next[0] {
+		stats.Records++
+		stats.Levels[node.height]++
+		if prev != skipList.head && skipList.super.compare(prev.key, node.key, kSize) == 0 {
+			continue
+		}
+		keys++
+		links += slSearchPath(skipList, node.key)
+	}
+	if keys > 0 {
+		stats.AvgSearchPath = float64(links) / float64(keys)
+	}
+	return stats
+}
 
-			println("k: ", key, "(v: ", val, ") [l: ", level, "]")
-		} else if skipList.super.kType == KeyTypeNullTerminatedString {
-			key := *((*string)(cursor.next[0].key))
-			val := *((*V)(cursor.next[0].val))
-			println("k: ", key, "(v: ", val, ") [l: ", level, "]")
+// slSearchPath counts the links a search for the first node of key follows,
+// one for every level dropped and the last step onto the node.
+func slSearchPath(skipList *ionSkipList, key IonKey) int {
+	kSize := skipList.super.record.keySize
+	links := 0
+	cursor := skipList.head
+	var h ionSlLevel
+	for h = skipList.head.height; h >= 0; h-- {
+		for cursor.next[h] != nil && skipList.super.compare(cursor.next[h].key, key, kSize) < 0 {
+			cursor = cursor.next[h]
+			links++
 		}
-		cursor = cursor.next[0]
+		links++
 	}
-	println("end")
+	return links
 }
@@ -1,7 +1,10 @@
 package iondb
 
 import (
+	"os"
+	"strings"
 	"testing"
+	"time"
 	"unsafe"
 )
 
@@ -84,7 +87,7 @@ func TestDictCreation(t *testing.T) {
 		createTestDictionary(&dict, &handler, &record, IonKeyType(kType), size, numElements)
 		skipList := (*ionSkipList)(unsafe.Pointer(dict.instance))
 		if slDebug {
-			printSkipList[string](skipList)
+			dictDump(&dict, os.Stdout, nil)
 		}
 		if dict.instance.kType != KeyTypeNumericSigned {
 			t.Errorf("got keyType = %v, want = %v", dict.instance.kType, KeyTypeNumericSigned)
@@ -395,3 +398,636 @@ func TestSkipListDeleteSingle(t *testing.T) {
 }
 
 var _ IonDictionaryHandler = slDictHandler{}
+var _ Dictionary[int, int] = (*SkipList[int, int])(nil)
+
+func TestSkipListBulkLoad(t *testing.T) {
+	one := 1
+	keys := []int{1, 2, 2, 2, 5, 8, 13, 13, 21}
+	sequence := func(keys []int) func() (int, int, bool) {
+		i := 0
+		return func() (int, int, bool) {
+			if i == len(keys) {
+				return 0, 0, false
+			}
+			i++
+			return keys[i-1], keys[i-1] * 10, true
+		}
+	}
+
+	t.Run("sorted input", func(t *testing.T) {
+		dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(one)), 7)
+		dict.Insert(0, 0)
+		status := dict.BulkLoad(sequence(keys))
+		if status.Err != ErrOk {
+			t.Errorf("got err = %v, want = %v", status.Err, ErrOk)
+		}
+		if status.ResCnt != IonResultCount(len(keys)) {
+			t.Errorf("got resCnt = %v, want = %v", status.ResCnt, len(keys))
+		}
+
+		want := append([]int{0}, keys...)
+		i := 0
+		cursor := dict.AllRecords()
+		for cursor.Next(); cursor.HasNext(); cursor.Next() {
+			if i < len(want) && cursor.GetKey() != want[i] {
+				t.Errorf("got key = %v, want = %v", cursor.GetKey(), want[i])
+			}
+			i++
+		}
+		if i != len(want) {
+			t.Errorf("got count = %v, want = %v", i, len(want))
+		}
+		if got := dict.Get(13); got != 130 {
+			t.Errorf("got val = %v, want = %v", got, 130)
+		}
+	})
+
+	t.Run("below the largest key", func(t *testing.T) {
+		dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(one)), 7, WithSeed(6))
+		for _, key := range []int{4, 10} {
+			dict.Insert(key, key*10)
+		}
+		loaded := []int{1, 2, 4, 4, 9, 10, 11, 11, 30}
+		status := dict.BulkLoad(sequence(loaded))
+		if status.Err != ErrOk || status.ResCnt != IonResultCount(len(loaded)) {
+			t.Errorf("got status = %v, want = %v", status, IonStatus{ErrOk, IonResultCount(len(loaded))})
+		}
+
+		want := []int{1, 2, 4, 4, 4, 9, 10, 10, 11, 11, 30}
+		var got []int
+		cursor := dict.AllRecords()
+		for cursor.Next(); cursor.HasNext(); cursor.Next() {
+			got = append(got, cursor.GetKey())
+		}
+		if len(got) != len(want) {
+			t.Fatalf("got keys = %v, want = %v", got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("got keys = %v, want = %v", got, want)
+			}
+		}
+		if got := dict.Rank(10); got != 6 {
+			t.Errorf("got rank(10) = %v, want = %v", got, 6)
+		}
+		if err := dict.Validate(); err != nil {
+			t.Errorf("got invalid skip list: %v", err)
+		}
+	})
+
+	t.Run("sorted order violation", func(t *testing.T) {
+		dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(one)), 7)
+		status := dict.BulkLoad(sequence([]int{1, 3, 2, 4}))
+		if status.Err != ErrSortedOrderViolation {
+			t.Errorf("got err = %v, want = %v", status.Err, ErrSortedOrderViolation)
+		}
+		if status.ResCnt != 2 {
+			t.Errorf("got resCnt = %v, want = %v", status.ResCnt, 2)
+		}
+		dict.Get(4)
+		if dict.LastStatus.Err != ErrItemNotFound {
+			t.Errorf("got err = %v, want = %v", dict.LastStatus.Err, ErrItemNotFound)
+		}
+	})
+}
+
+func TestSkipListBatch(t *testing.T) {
+	one := 1
+	dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(one)), 7)
+	dict.Insert(40, 400)
+
+	t.Run("insert batch", func(t *testing.T) {
+		keys := []int{30, 10, 50, 20, 10, 60}
+		vals := []int{300, 100, 500, 200, 101, 600}
+		statuses := dict.InsertBatch(keys, vals)
+		for i, status := range statuses {
+			if status.Err != ErrOk || status.ResCnt != 1 {
+				t.Errorf("got status[%v] = %v, want = %v", i, status, IonStatus{ErrOk, 1})
+			}
+		}
+		want := []int{10, 10, 20, 30, 40, 50, 60}
+		i := 0
+		cursor := dict.AllRecords()
+		for cursor.Next(); cursor.HasNext(); cursor.Next() {
+			if i < len(want) && cursor.GetKey() != want[i] {
+				t.Errorf("got key = %v, want = %v", cursor.GetKey(), want[i])
+			}
+			i++
+		}
+		if i != len(want) {
+			t.Errorf("got count = %v, want = %v", i, len(want))
+		}
+	})
+
+	t.Run("insert batch length mismatch", func(t *testing.T) {
+		statuses := dict.InsertBatch([]int{1, 2, 3}, []int{1})
+		if len(statuses) != 3 {
+			t.Fatalf("got statuses = %v, want = %v", len(statuses), 3)
+		}
+		for i, status := range statuses {
+			if status.Err != ErrOutOfBounds {
+				t.Errorf("got err[%v] = %v, want = %v", i, status.Err, ErrOutOfBounds)
+			}
+		}
+		if dict.LastStatus.Err != ErrOutOfBounds {
+			t.Errorf("got last err = %v, want = %v", dict.LastStatus.Err, ErrOutOfBounds)
+		}
+		if _, statuses := dict.GetBatch([]int{1, 2, 3}); statuses[0].Err != ErrItemNotFound {
+			t.Errorf("got err = %v, want = %v", statuses[0].Err, ErrItemNotFound)
+		}
+	})
+
+	t.Run("get batch", func(t *testing.T) {
+		vals, statuses := dict.GetBatch([]int{50, 5, 20, 40})
+		wantVals := []int{500, 0, 200, 400}
+		wantErrs := []IonErr{ErrOk, ErrItemNotFound, ErrOk, ErrOk}
+		for i := range vals {
+			if vals[i] != wantVals[i] {
+				t.Errorf("got val[%v] = %v, want = %v", i, vals[i], wantVals[i])
+			}
+			if statuses[i].Err != wantErrs[i] {
+				t.Errorf("got err[%v] = %v, want = %v", i, statuses[i].Err, wantErrs[i])
+			}
+		}
+	})
+
+	t.Run("delete batch", func(t *testing.T) {
+		statuses := dict.DeleteBatch([]int{60, 10, 99, 40})
+		wantCnts := []IonResultCount{1, 2, 0, 1}
+		for i := range statuses {
+			if statuses[i].ResCnt != wantCnts[i] {
+				t.Errorf("got resCnt[%v] = %v, want = %v", i, statuses[i].ResCnt, wantCnts[i])
+			}
+		}
+		if statuses[2].Err != ErrItemNotFound {
+			t.Errorf("got err = %v, want = %v", statuses[2].Err, ErrItemNotFound)
+		}
+		for _, key := range []int{10, 40, 60} {
+			dict.Get(key)
+			if dict.LastStatus.Err != ErrItemNotFound {
+				t.Errorf("got err = %v for key %v, want = %v", dict.LastStatus.Err, key, ErrItemNotFound)
+			}
+		}
+		if got := dict.Get(30); got != 300 {
+			t.Errorf("got val = %v, want = %v", got, 300)
+		}
+	})
+}
+
+func TestSkipListUniqueKeys(t *testing.T) {
+	one := 1
+	dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(one)), 7, WithUniqueKeys())
+
+	t.Run("insert duplicate", func(t *testing.T) {
+		if status := dict.Insert(3, 30); status.Err != ErrOk {
+			t.Errorf("got err = %v, want = %v", status.Err, ErrOk)
+		}
+		status := dict.Insert(3, 31)
+		if status.Err != ErrDuplicateKey {
+			t.Errorf("got err = %v, want = %v", status.Err, ErrDuplicateKey)
+		}
+		if status.ResCnt != 0 {
+			t.Errorf("got resCnt = %v, want = %v", status.ResCnt, 0)
+		}
+		if got := dict.Get(3); got != 30 {
+			t.Errorf("got val = %v, want = %v", got, 30)
+		}
+	})
+
+	t.Run("update upserts", func(t *testing.T) {
+		dict.Update(3, 32)
+		dict.Update(4, 40)
+		if got := dict.Get(3); got != 32 {
+			t.Errorf("got val = %v, want = %v", got, 32)
+		}
+		if got := dict.Get(4); got != 40 {
+			t.Errorf("got val = %v, want = %v", got, 40)
+		}
+	})
+
+	t.Run("insert batch duplicate", func(t *testing.T) {
+		statuses := dict.InsertBatch([]int{5, 4, 5}, []int{50, 41, 51})
+		want := []IonErr{ErrOk, ErrDuplicateKey, ErrDuplicateKey}
+		for i := range statuses {
+			if statuses[i].Err != want[i] {
+				t.Errorf("got err[%v] = %v, want = %v", i, statuses[i].Err, want[i])
+			}
+		}
+	})
+
+	t.Run("config", func(t *testing.T) {
+		if conf := dict.Config(); !conf.uniqueKeys {
+			t.Errorf("got uniqueKeys = %v, want = %v", conf.uniqueKeys, true)
+		}
+	})
+}
+
+func TestSkipListDuplicates(t *testing.T) {
+	one := 1
+	newDict := func() *SkipList[int, int] {
+		dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(one)), 7)
+		for i := 0; i < 10; i++ {
+			dict.Insert(i, i)
+		}
+		dict.Insert(5, 51)
+		dict.Insert(5, 52)
+		dict.Insert(5, 53)
+		return dict
+	}
+	equalValues := func(got []int, want []int) bool {
+		if len(got) != len(want) {
+			return false
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	t.Run("get all", func(t *testing.T) {
+		dict := newDict()
+		if got := dict.GetAll(5); !equalValues(got, []int{5, 51, 52, 53}) {
+			t.Errorf("got vals = %v, want = %v", got, []int{5, 51, 52, 53})
+		}
+		if got := dict.GetAll(42); len(got) != 0 || dict.LastStatus.Err != ErrItemNotFound {
+			t.Errorf("got vals = %v, err = %v, want = %v, %v", got, dict.LastStatus.Err, []int{}, ErrItemNotFound)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		delete int
+		want   []int
+	}{
+		{name: "delete first", delete: 5, want: []int{51, 52, 53}},
+		{name: "delete middle", delete: 52, want: []int{5, 51, 53}},
+		{name: "delete last", delete: 53, want: []int{5, 51, 52}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dict := newDict()
+			status := dict.DeleteValue(5, tt.delete)
+			if status.Err != ErrOk || status.ResCnt != 1 {
+				t.Errorf("got status = %v, want = %v", status, IonStatus{ErrOk, 1})
+			}
+			if got := dict.GetAll(5); !equalValues(got, tt.want) {
+				t.Errorf("got vals = %v, want = %v", got, tt.want)
+			}
+			if got := dict.Get(6); got != 6 {
+				t.Errorf("got val = %v, want = %v", got, 6)
+			}
+		})
+	}
+
+	t.Run("delete only record", func(t *testing.T) {
+		dict := newDict()
+		dict.DeleteValue(7, 7)
+		dict.Get(7)
+		if dict.LastStatus.Err != ErrItemNotFound {
+			t.Errorf("got err = %v, want = %v", dict.LastStatus.Err, ErrItemNotFound)
+		}
+		if status := dict.DeleteValue(5, 99); status.Err != ErrItemNotFound {
+			t.Errorf("got err = %v, want = %v", status.Err, ErrItemNotFound)
+		}
+	})
+
+	t.Run("update one duplicate", func(t *testing.T) {
+		dict := newDict()
+		dict.UpdateAt(5, 2, 62)
+		dict.UpdateValue(5, 51, 61)
+		if got := dict.GetAll(5); !equalValues(got, []int{5, 61, 62, 53}) {
+			t.Errorf("got vals = %v, want = %v", got, []int{5, 61, 62, 53})
+		}
+		if status := dict.UpdateAt(5, 4, 0); status.Err != ErrItemNotFound {
+			t.Errorf("got err = %v, want = %v", status.Err, ErrItemNotFound)
+		}
+	})
+
+	t.Run("string values match by content", func(t *testing.T) {
+		str := ""
+		dict := NewSkipList[int, string](-1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(str)), 7)
+		dict.Insert(1, "aaa")
+		dict.Insert(1, "bbb")
+		dict.Insert(1, "ccc")
+		if status := dict.UpdateValue(1, strings.Repeat("b", 3), "ddd"); status.Err != ErrOk {
+			t.Errorf("got update err = %v, want = %v", status.Err, ErrOk)
+		}
+		if status := dict.DeleteValue(1, strings.Repeat("a", 3)); status.Err != ErrOk {
+			t.Errorf("got delete err = %v, want = %v", status.Err, ErrOk)
+		}
+		want := []string{"ddd", "ccc"}
+		if got := dict.GetAll(1); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+			t.Errorf("got vals = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("values that can't be compared", func(t *testing.T) {
+		var vals []int
+		dict := NewSkipList[int, []int](-1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(vals)), 7)
+		dict.Insert(1, []int{1})
+		if status := dict.DeleteValue(1, []int{1}); status.Err != ErrUnableToConvert {
+			t.Errorf("got delete err = %v, want = %v", status.Err, ErrUnableToConvert)
+		}
+		if status := dict.UpdateValue(1, []int{1}, []int{2}); status.Err != ErrUnableToConvert {
+			t.Errorf("got update err = %v, want = %v", status.Err, ErrUnableToConvert)
+		}
+	})
+}
+
+func TestSkipListTTL(t *testing.T) {
+	one := 1
+	now := time.Unix(1000, 0)
+	clock := func() time.Time { return now }
+	dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(one)), 7, WithTTL(10*time.Second), WithClock(clock))
+	for i := 0; i < 5; i++ {
+		dict.Insert(i, i)
+	}
+	dict.InsertWithTTL(2, 21, time.Minute)
+	dict.InsertWithTTL(7, 7, time.Minute)
+	dict.InsertWithTTL(8, 8, 0)
+
+	t.Run("not yet expired", func(t *testing.T) {
+		if got := dict.Get(3); got != 3 || dict.LastStatus.Err != ErrOk {
+			t.Errorf("got val = %v, err = %v, want = %v, %v", got, dict.LastStatus.Err, 3, ErrOk)
+		}
+	})
+
+	now = now.Add(15 * time.Second)
+
+	t.Run("get hides expired", func(t *testing.T) {
+		dict.Get(3)
+		if dict.LastStatus.Err != ErrItemNotFound {
+			t.Errorf("got err = %v, want = %v", dict.LastStatus.Err, ErrItemNotFound)
+		}
+		if got := dict.Get(2); got != 21 {
+			t.Errorf("got val = %v, want = %v", got, 21)
+		}
+	})
+
+	t.Run("cursor hides expired", func(t *testing.T) {
+		want := []int{2, 7, 8}
+		got := collectKeys(dict.AllRecords())
+		if len(got) != len(want) {
+			t.Fatalf("got keys = %v, want = %v", got, want)
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Errorf("got keys = %v, want = %v", got, want)
+			}
+		}
+		skipList := (*ionSkipList)(unsafe.Pointer(dict.dict.instance))
+		if first := *((*int)(skipList.head.next[0].key)); first != 2 {
+			t.Errorf("got first key = %v, want = %v", first, 2)
+		}
+	})
+
+	now = now.Add(time.Hour)
+
+	t.Run("sweep", func(t *testing.T) {
+		status := dict.Sweep()
+		if status.ResCnt != 2 {
+			t.Errorf("got resCnt = %v, want = %v", status.ResCnt, 2)
+		}
+		if got := dict.Get(8); got != 8 {
+			t.Errorf("got val = %v, want = %v", got, 8)
+		}
+	})
+
+	t.Run("update upserts expired", func(t *testing.T) {
+		dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(one)), 7, WithClock(clock))
+		dict.InsertWithTTL(1, 10, time.Second)
+		dict.InsertWithTTL(1, 11, time.Second)
+		dict.InsertWithTTL(2, 20, time.Second)
+		dict.Insert(2, 21)
+		now = now.Add(time.Minute)
+
+		if status := dict.Update(1, 12); status != (IonStatus{ErrOk, 1}) {
+			t.Errorf("got status = %v, want = %v", status, IonStatus{ErrOk, 1})
+		}
+		if got := dict.Get(1); got != 12 || dict.LastStatus.Err != ErrOk {
+			t.Errorf("got val = %v, err = %v, want = %v, %v", got, dict.LastStatus.Err, 12, ErrOk)
+		}
+		now = now.Add(time.Hour)
+		if got := dict.Get(1); got != 12 {
+			t.Errorf("got val = %v later, want = %v", got, 12)
+		}
+
+		if status := dict.Update(2, 22); status != (IonStatus{ErrOk, 1}) {
+			t.Errorf("got status = %v, want = %v", status, IonStatus{ErrOk, 1})
+		}
+		if got := dict.GetAll(2); len(got) != 1 || got[0] != 22 {
+			t.Errorf("got vals = %v, want = %v", got, []int{22})
+		}
+		if err := dict.Validate(); err != nil {
+			t.Errorf("got invalid skip list: %v", err)
+		}
+	})
+
+	t.Run("single duplicates skip expired", func(t *testing.T) {
+		dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(one)), 7, WithClock(clock))
+		dict.InsertWithTTL(5, 1, time.Second)
+		dict.Insert(5, 2)
+		dict.InsertWithTTL(6, 1, time.Second)
+		now = now.Add(time.Minute)
+
+		if status := dict.UpdateAt(5, 0, 9); status != (IonStatus{ErrOk, 1}) {
+			t.Errorf("got status = %v, want = %v", status, IonStatus{ErrOk, 1})
+		}
+		if got := dict.GetAll(5); len(got) != 1 || got[0] != 9 {
+			t.Errorf("got vals = %v, want = %v", got, []int{9})
+		}
+		dict.InsertWithTTL(5, 1, time.Second)
+		now = now.Add(time.Minute)
+		if status := dict.DeleteValue(5, 1); status.Err != ErrItemNotFound {
+			t.Errorf("got err = %v deleting an expired value, want = %v", status.Err, ErrItemNotFound)
+		}
+		if status := dict.UpdateValue(6, 1, 3); status.Err != ErrItemNotFound {
+			t.Errorf("got err = %v updating an expired value, want = %v", status.Err, ErrItemNotFound)
+		}
+		if got := dict.GetAll(5); len(got) != 1 || got[0] != 9 {
+			t.Errorf("got vals = %v, want = %v", got, []int{9})
+		}
+		if err := dict.Validate(); err != nil {
+			t.Errorf("got invalid skip list: %v", err)
+		}
+	})
+}
+
+func TestSkipListLevelGeneration(t *testing.T) {
+	one := 1
+	kSize := int(unsafe.Sizeof(one))
+	vSize := uint(unsafe.Sizeof(one))
+	heights := func(dict *SkipList[int, int]) []ionSlLevel {
+		var got []ionSlLevel
+		skipList := (*ionSkipList)(unsafe.Pointer(dict.dict.instance))
+		for node := skipList.head.next[0]; node != nil; node = node.next[0] {
+			got = append(got, node.height)
+		}
+		return got
+	}
+
+	t.Run("same seed same structure", func(t *testing.T) {
+		first := NewSkipList[int, int](-1, KeyTypeNumericSigned, kSize, vSize, 7, WithSeed(42))
+		second := NewSkipList[int, int](-1, KeyTypeNumericSigned, kSize, vSize, 7, WithSeed(42))
+		for key := 0; key < 100; key++ {
+			first.Insert(key, key)
+			second.Insert(key, key)
+		}
+		a, b := heights(first), heights(second)
+		for i := range a {
+			if a[i] != b[i] {
+				t.Fatalf("got height %v = %v, want = %v", i, b[i], a[i])
+			}
+		}
+	})
+
+	t.Run("probability and max height", func(t *testing.T) {
+		dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, kSize, vSize, 7, WithSeed(1), WithLevelProbability(1, 2), WithMaxHeight(3))
+		if dict.LastStatus.Err != ErrOk {
+			t.Fatalf("got err = %v, want = %v", dict.LastStatus.Err, ErrOk)
+		}
+		for key := 0; key < 1000; key++ {
+			dict.Insert(key, key)
+		}
+		stats := dict.Stats()
+		if len(stats.Levels) != 3 {
+			t.Fatalf("got levels = %v, want = %v", len(stats.Levels), 3)
+		}
+		// about half of the nodes stop at level 0 with p = 1/2.
+		if stats.Levels[0] < 400 || stats.Levels[0] > 600 {
+			t.Errorf("got level 0 nodes = %v, want = about %v", stats.Levels[0], 500)
+		}
+		if conf := dict.Config(); conf.dictSize != 3 || conf.pnum != 1 || conf.pden != 2 || conf.seed != 1 {
+			t.Errorf("got config = %v, %v/%v, seed %v, want = %v, %v/%v, seed %v", conf.dictSize, conf.pnum, conf.pden, conf.seed, 3, 1, 2, 1)
+		}
+	})
+
+	t.Run("config reports the seed", func(t *testing.T) {
+		first := NewSkipList[int, int](-1, KeyTypeNumericSigned, kSize, vSize, 7)
+		conf := first.Config()
+		if !conf.seeded {
+			t.Fatalf("got seeded = %v, want = %v", conf.seeded, true)
+		}
+		second := NewSkipList[int, int](-1, KeyTypeNumericSigned, kSize, vSize, 7, WithSeed(conf.seed))
+		for key := 0; key < 100; key++ {
+			first.Insert(key, key)
+			second.Insert(key, key)
+		}
+		a, b := heights(first), heights(second)
+		for i := range a {
+			if a[i] != b[i] {
+				t.Fatalf("got height %v = %v, want = %v", i, b[i], a[i])
+			}
+		}
+	})
+
+	t.Run("invalid probability", func(t *testing.T) {
+		dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, kSize, vSize, 7, WithLevelProbability(2, 2))
+		if dict.LastStatus.Err != ErrOutOfBounds {
+			t.Errorf("got err = %v, want = %v", dict.LastStatus.Err, ErrOutOfBounds)
+		}
+	})
+
+	t.Run("stats of a linked list", func(t *testing.T) {
+		dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, kSize, vSize, 1)
+		for key := 1; key <= 9; key++ {
+			dict.Insert(key, key)
+		}
+		dict.Insert(5, 50)
+		stats := dict.Stats()
+		if stats.Records != 10 || stats.Levels[0] != 10 {
+			t.Errorf("got records = %v, %v, want = %v, %v", stats.Records, stats.Levels[0], 10, 10)
+		}
+		// reaching key i takes i links plus one for the duplicate of 5 on
+		// the way to keys above it, the duplicate is not searched for itself.
+		if want := 49.0 / 9; stats.AvgSearchPath != want {
+			t.Errorf("got avg search path = %v, want = %v", stats.AvgSearchPath, want)
+		}
+	})
+}
+
+func TestSkipListMemoryBudget(t *testing.T) {
+	one := 1
+	kSize := int(unsafe.Sizeof(one))
+	vSize := uint(unsafe.Sizeof(one))
+	// what the nodes actually hold, to check the running count against.
+	walkUsage := func(dict *SkipList[int, int]) IonMemoryUsage {
+		skipList := (*ionSkipList)(unsafe.Pointer(dict.dict.instance))
+		usage := IonMemoryUsage{Bytes: int(unsafe.Sizeof(ionSlNode{})) + slLinkBytes(skipList.maxheight)}
+		for node := skipList.head.next[0]; node != nil; node = node.next[0] {
+			usage.Records++
+			usage.Bytes += slNodeBytes(skipList, node.height)
+		}
+		return usage
+	}
+
+	t.Run("record budget", func(t *testing.T) {
+		dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, kSize, vSize, 7, WithRecordBudget(3))
+		for key := 1; key <= 3; key++ {
+			if status := dict.Insert(key, key); status.Err != ErrOk {
+				t.Fatalf("got err = %v, want = %v", status.Err, ErrOk)
+			}
+		}
+		if status := dict.Insert(4, 4); status.Err != ErrMaxCapacity || status.ResCnt != 0 {
+			t.Errorf("got status = %v, want = %v", status, IonStatus{ErrMaxCapacity, 0})
+		}
+		if status := dict.Update(4, 4); status.Err != ErrMaxCapacity {
+			t.Errorf("got err = %v, want = %v", status.Err, ErrMaxCapacity)
+		}
+		statuses := dict.InsertBatch([]int{5, 6}, []int{5, 6})
+		if statuses[0].Err != ErrMaxCapacity || statuses[1].Err != ErrMaxCapacity {
+			t.Errorf("got batch = %v, want = %v", statuses, ErrMaxCapacity)
+		}
+		dict.DeleteRecord(2)
+		if status := dict.Insert(4, 4); status.Err != ErrOk {
+			t.Errorf("got err = %v, want = %v", status.Err, ErrOk)
+		}
+		if got := dict.MemoryUsage().Records; got != 3 {
+			t.Errorf("got records = %v, want = %v", got, 3)
+		}
+	})
+
+	t.Run("byte budget", func(t *testing.T) {
+		empty := NewSkipList[int, int](-1, KeyTypeNumericSigned, kSize, vSize, 1)
+		skipList := (*ionSkipList)(unsafe.Pointer(empty.dict.instance))
+		budget := empty.MemoryUsage().Bytes + 5*slNodeBytes(skipList, 0)
+
+		dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, kSize, vSize, 1, WithMemoryBudget(budget))
+		inserted := 0
+		for key := 0; key < 10; key++ {
+			if dict.Insert(key, key).Err == ErrOk {
+				inserted++
+			}
+		}
+		if inserted != 5 {
+			t.Errorf("got inserted = %v, want = %v", inserted, 5)
+		}
+		if got := dict.MemoryUsage().Bytes; got != budget {
+			t.Errorf("got bytes = %v, want = %v", got, budget)
+		}
+	})
+
+	t.Run("usage follows deletes", func(t *testing.T) {
+		dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, kSize, vSize, 7, WithSeed(3))
+		for key := 0; key < 50; key++ {
+			dict.Insert(key%20, key)
+		}
+		dict.DeleteValue(4, 4)
+		dict.DeleteValue(5, 25)
+		dict.DeleteRecord(6)
+		dict.DeleteBatch([]int{7, 8})
+		if got, want := dict.MemoryUsage(), walkUsage(dict); got != want {
+			t.Errorf("got usage = %v, want = %v", got, want)
+		}
+		for key := 0; key < 20; key++ {
+			dict.DeleteRecord(key)
+		}
+		if got, want := dict.MemoryUsage(), walkUsage(dict); got != want || got.Records != 0 {
+			t.Errorf("got usage = %v, want = %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package iondb
+
+import "unsafe"
+
+// ionSlArena hands out skip list nodes carved from slabs instead of making a
+// node, a key, a value and two link arrays per insert. Every node height has
+// its own slabs, so a node's links are sized for it, and its own free list
+// of nodes to reuse. A slab is four allocations, for the nodes, their
+// next and width links and their key and value bytes, however many nodes
+// it holds.
+type ionSlArena struct {
+	nodesPerSlab int
+	keyStride    uintptr
+	stride       uintptr
+	classes      []ionSlSlabClass
+	// bytes held by every slab, in use or not.
+	bytes int
+}
+
+type ionSlSlabClass struct {
+	// nodes per slab of this height, fewer for the taller, rarer heights.
+	slabNodes int
+	nodes     []ionSlNode
+	links     []*ionSlNode
+	widths    []int
+	data      unsafe.Pointer
+	used      int
+	free      []*ionSlNode
+}
+
+// slNewArena sizes a slab of the lowest nodes to nodesPerSlab, and the slabs
+// above to the share of nodes that reach that high with pnum/pden.
+func slNewArena(nodesPerSlab int, kSize IonKeySize, vSize IonValueSize, maxheight ionSlLevel, pnum int, pden int) *ionSlArena {
+	arena := new(ionSlArena)
+	arena.nodesPerSlab = nodesPerSlab
+	arena.keyStride = slWordAlign(uintptr(kSize))
+	arena.stride = arena.keyStride + slWordAlign(uintptr(vSize))
+	arena.classes = make([]ionSlSlabClass, maxheight)
+	share := nodesPerSlab
+	for h := range arena.classes {
+		if share < 1 {
+			share = 1
+		}
+		arena.classes[h].slabNodes = share
+		share = share * pnum / pden
+	}
+	return arena
+}
+
+func slWordAlign(size uintptr) uintptr {
+	word := unsafe.Sizeof(uintptr(0))
+	return (size + word - 1) / word * word
+}
+
+// node returns a node of height with zeroed links, reusing a freed one
+// when there is one. Its key and value have to be written by the caller.
+func (arena *ionSlArena) node(height ionSlLevel) *ionSlNode {
+	class := &arena.classes[height]
+	if last := len(class.free) - 1; last >= 0 {
+		node := class.free[last]
+		class.free = class.free[:last]
+		for h := range node.next {
+			node.next[h] = nil
+			node.width[h] = 0
+		}
+		node.expires = 0
+		return node
+	}
+
+	levels := int(height) + 1
+	if class.used == len(class.nodes) {
+		class.nodes = make([]ionSlNode, class.slabNodes)
+		class.links = make([]*ionSlNode, class.slabNodes*levels)
+		class.widths = make([]int, class.slabNodes*levels)
+		class.data = alloc(uintptr(class.slabNodes)*arena.stride, nil)
+		class.used = 0
+		arena.bytes += class.slabNodes * (int(unsafe.Sizeof(ionSlNode{})) + slLinkBytes(ionSlLevel(levels)) + int(arena.stride))
+	}
+	i := class.used
+	class.used++
+
+	node := &class.nodes[i]
+	node.height = height
+	node.next = class.links[i*levels : (i+1)*levels : (i+1)*levels]
+	node.width = class.widths[i*levels : (i+1)*levels : (i+1)*levels]
+	node.key = IonKey(unsafe.Add(class.data, uintptr(i)*arena.stride))
+	node.val = IonValue(unsafe.Add(class.data, uintptr(i)*arena.stride+arena.keyStride))
+	return node
+}
+
+// release keeps node for reuse by a later node of the same height.
+func (arena *ionSlArena) release(node *ionSlNode) {
+	class := &arena.classes[node.height]
+	class.free = append(class.free, node)
+}
@@ -0,0 +1,108 @@
+package iondb
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestSlabAllocation(t *testing.T) {
+	one := 1
+	kSize := int(unsafe.Sizeof(one))
+	vSize := uint(unsafe.Sizeof(one))
+	plain := NewSkipList[int, int](-1, KeyTypeNumericSigned, kSize, vSize, 7, WithSeed(9))
+	slab := NewSkipList[int, int](-1, KeyTypeNumericSigned, kSize, vSize, 7, WithSeed(9), WithSlabAllocation(8))
+
+	for _, dict := range []*SkipList[int, int]{plain, slab} {
+		for key := 0; key < 100; key++ {
+			dict.Insert(key%30, key)
+		}
+		dict.DeleteRecord(3)
+		dict.DeleteValue(4, 4)
+		dict.DeleteBatch([]int{10, 11, 12})
+		for key := 100; key < 120; key++ {
+			dict.Insert(key%40, key)
+		}
+	}
+
+	t.Run("same records", func(t *testing.T) {
+		want := plain.AllRecords()
+		got := slab.AllRecords()
+		for want.Next() {
+			if !got.Next() {
+				t.Fatalf("got end of records, want = %v", want.GetKey())
+			}
+			if got.GetKey() != want.GetKey() || got.GetValue() != want.GetValue() {
+				t.Errorf("got record = %v, %v, want = %v, %v", got.GetKey(), got.GetValue(), want.GetKey(), want.GetValue())
+			}
+		}
+		if got.Next() {
+			t.Errorf("got extra record = %v", got.GetKey())
+		}
+	})
+
+	t.Run("deleted nodes are reused", func(t *testing.T) {
+		// one level, so every node comes from the same slabs.
+		dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, kSize, vSize, 1, WithSlabAllocation(4))
+		for key := 0; key < 6; key++ {
+			dict.Insert(key, key)
+		}
+		class := &(*ionSkipList)(unsafe.Pointer(dict.dict.instance)).arena.classes[0]
+		used := class.used
+		dict.DeleteRecord(2)
+		if len(class.free) != 1 {
+			t.Fatalf("got free nodes = %v, want = %v", len(class.free), 1)
+		}
+		freed := class.free[0]
+		dict.Insert(9, 90)
+		if len(class.free) != 0 || class.used != used || !slabHolds((*ionSkipList)(unsafe.Pointer(dict.dict.instance)), freed) {
+			t.Errorf("got free nodes = %v, used = %v, want the freed node reused", len(class.free), class.used)
+		}
+		if got := dict.Get(9); got != 90 {
+			t.Errorf("got val = %v, want = %v", got, 90)
+		}
+	})
+}
+
+// slabHolds reports whether node is linked into the skip list.
+func slabHolds(skipList *ionSkipList, node *ionSlNode) bool {
+	for cursor := skipList.head.next[0]; cursor != nil; cursor = cursor.next[0] {
+		if cursor == node {
+			return true
+		}
+	}
+	return false
+}
+
+func benchmarkSkipListInsert(b *testing.B, opts ...IonDictionaryOption) {
+	one := 1
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(one)), 7, opts...)
+		for key := 0; key < 1000; key++ {
+			dict.Insert(key, key)
+		}
+	}
+}
+
+func BenchmarkSkipListInsertHeap(b *testing.B) {
+	benchmarkSkipListInsert(b)
+}
+
+func BenchmarkSkipListInsertSlab(b *testing.B) {
+	benchmarkSkipListInsert(b, WithSlabAllocation(256))
+}
+
+func BenchmarkSkipListChurnSlab(b *testing.B) {
+	one := 1
+	dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, int(unsafe.Sizeof(one)), uint(unsafe.Sizeof(one)), 7, WithSlabAllocation(256))
+	for key := 0; key < 1000; key++ {
+		dict.Insert(key, key)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := i % 1000
+		dict.DeleteRecord(key)
+		dict.Insert(key, i)
+	}
+}
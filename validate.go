@@ -0,0 +1,124 @@
+package iondb
+
+import (
+	"strconv"
+	"unsafe"
+)
+
+// IonValidationError tells where a skip list breaks one of its invariants.
+type IonValidationError struct {
+	Level int
+	// Position of the node on level 0, counting the first node as 1 and the
+	// head as 0.
+	Position int
+	Reason   string
+}
+
+func (e *IonValidationError) Error() string {
+	return "skip list level " + strconv.Itoa(e.Level) + " position " + strconv.Itoa(e.Position) + ": " + e.Reason
+}
+
+// Validate checks the structure of the skip list: every level is sorted, a
+// node is only linked on levels up to its height and on all of them,
+// duplicates sit together on level 0 behind the only node of their key that
+// can be taller, the link widths and record count add up, and no deleted
+// node can still be reached. It returns the first broken invariant.
+func (sl *SkipList[K, V]) Validate() error {
+	return slValidate((*ionSkipList)(unsafe.Pointer(sl.dict.instance)))
+}
+
+// slDebugCheck validates the skip list after a change in debug builds.
+func slDebugCheck(skipList *ionSkipList) {
+	if !slDebug {
+		return
+	}
+	if err := slValidate(skipList); err != nil {
+		panic(err)
+	}
+}
+
+func slValidate(skipList *ionSkipList) error {
+	kSize := skipList.super.record.keySize
+	head := skipList.head
+	if head == nil {
+		return &IonValidationError{Reason: "the skip list has no head"}
+	}
+	levels := int(skipList.maxheight)
+	if int(head.height) != levels-1 || len(head.next) != levels || len(head.width) != levels {
+		return &IonValidationError{Reason: "the head does not span every level"}
+	}
+
+	freed := make(map[*ionSlNode]bool)
+	if skipList.arena != nil {
+		for _, class := range skipList.arena.classes {
+			for _, node := range class.free {
+				freed[node] = true
+			}
+		}
+	}
+
+	// level 0 holds every record, so it gives each node its position.
+	pos := map[*ionSlNode]int{head: 0}
+	tall := make([]int, levels)
+	count := 0
+	var prev *ionSlNode
+	for node := head.next[0]; node != nil; prev, node = node, node.next[0] {
+		count++
+		if _, seen := pos[node]; seen {
+			return &IonValidationError{Position: count, Reason: "level 0 runs into a cycle"}
+		}
+		pos[node] = count
+		if node.key == nil || node.val == nil {
+			return &IonValidationError{Position: count, Reason: "the node has no key or value, it was destroyed"}
+		}
+		if freed[node] {
+			return &IonValidationError{Position: count, Reason: "the node was deleted but is still linked"}
+		}
+		if node.height < 0 || int(node.height) >= levels || len(node.next) != int(node.height)+1 || len(node.width) != int(node.height)+1 {
+			return &IonValidationError{Position: count, Reason: "the links do not match the node height " + strconv.Itoa(int(node.height))}
+		}
+		if prev != nil {
+			cmp := skipList.super.compare(prev.key, node.key, kSize)
+			if cmp > 0 {
+				return &IonValidationError{Position: count, Reason: "the key is smaller than the one before it"}
+			}
+			if cmp == 0 && node.height > 0 {
+				return &IonValidationError{Position: count, Reason: "a duplicate is taller than level 0"}
+			}
+		}
+		for h := 0; h <= int(node.height); h++ {
+			tall[h]++
+		}
+	}
+	if count != skipList.records {
+		return &IonValidationError{Reason: "the skip list counts " + strconv.Itoa(skipList.records) + " records but links " + strconv.Itoa(count)}
+	}
+
+	for h := 0; h < levels; h++ {
+		linked := 0
+		for node := head; node != nil; node = node.next[h] {
+			want := count - pos[node]
+			if next := node.next[h]; next != nil {
+				p, ok := pos[next]
+				if !ok {
+					return &IonValidationError{Level: h, Position: pos[node], Reason: "the next node is not on level 0"}
+				}
+				if int(next.height) < h {
+					return &IonValidationError{Level: h, Position: p, Reason: "the node is linked above its height " + strconv.Itoa(int(next.height))}
+				}
+				if p <= pos[node] {
+					return &IonValidationError{Level: h, Position: p, Reason: "the level goes back to an earlier node"}
+				}
+				want = p - pos[node]
+				linked++
+			}
+			if node.width[h] != want {
+				return &IonValidationError{Level: h, Position: pos[node], Reason: "the link width is " + strconv.Itoa(node.width[h]) + ", want " + strconv.Itoa(want)}
+			}
+		}
+		if linked != tall[h] {
+			return &IonValidationError{Level: h, Reason: strconv.Itoa(tall[h]) + " nodes reach the level but " + strconv.Itoa(linked) + " are linked on it"}
+		}
+	}
+	return nil
+}
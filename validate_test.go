@@ -0,0 +1,118 @@
+package iondb
+
+import (
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestSkipListValidate(t *testing.T) {
+	one := 1
+	kSize := int(unsafe.Sizeof(one))
+	vSize := uint(unsafe.Sizeof(one))
+	newDict := func() (*SkipList[int, int], *ionSkipList) {
+		dict := NewSkipList[int, int](-1, KeyTypeNumericSigned, kSize, vSize, 7, WithSeed(5), WithSlabAllocation(16))
+		for key := 0; key < 40; key++ {
+			dict.Insert(key%15, key)
+		}
+		dict.DeleteRecord(3)
+		dict.DeleteValue(4, 4)
+		return dict, (*ionSkipList)(unsafe.Pointer(dict.dict.instance))
+	}
+	// tallNode returns the first node above level 0 and its level 0 predecessor.
+	tallNode := func(skipList *ionSkipList) (*ionSlNode, *ionSlNode) {
+		prev := skipList.head
+		for node := skipList.head.next[0]; node != nil; prev, node = node, node.next[0] {
+			if node.height > 0 {
+				return node, prev
+			}
+		}
+		return nil, nil
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		dict, _ := newDict()
+		if err := dict.Validate(); err != nil {
+			t.Errorf("got err = %v, want = %v", err, nil)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		corrupt func(skipList *ionSkipList)
+		level   int
+		reason  string
+	}{
+		{
+			name: "unsorted keys",
+			corrupt: func(skipList *ionSkipList) {
+				*(*int)(skipList.head.next[0].key) = 99
+			},
+			reason: "the key is smaller than the one before it",
+		},
+		{
+			name: "tall duplicate",
+			corrupt: func(skipList *ionSkipList) {
+				node, prev := tallNode(skipList)
+				*(*int)(node.key) = *(*int)(prev.key)
+			},
+			reason: "a duplicate is taller than level 0",
+		},
+		{
+			name: "wrong width",
+			corrupt: func(skipList *ionSkipList) {
+				skipList.head.width[1]++
+			},
+			level:  1,
+			reason: "the link width is",
+		},
+		{
+			name: "linked above height",
+			corrupt: func(skipList *ionSkipList) {
+				node, _ := tallNode(skipList)
+				skipList.head.next[node.height+1] = node
+			},
+			level:  1,
+			reason: "the node is linked above its height",
+		},
+		{
+			name: "missing from a level",
+			corrupt: func(skipList *ionSkipList) {
+				node, _ := tallNode(skipList)
+				skipList.head.width[node.height] += node.width[node.height]
+				skipList.head.next[node.height] = node.next[node.height]
+			},
+			level:  1,
+			reason: "are linked on it",
+		},
+		{
+			name: "deleted node still linked",
+			corrupt: func(skipList *ionSkipList) {
+				skipList.arena.release(skipList.head.next[0])
+			},
+			reason: "the node was deleted but is still linked",
+		},
+		{
+			name: "record count",
+			corrupt: func(skipList *ionSkipList) {
+				skipList.records++
+			},
+			reason: "records but links",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dict, skipList := newDict()
+			tt.corrupt(skipList)
+			err := dict.Validate()
+			if err == nil {
+				t.Fatalf("got err = %v, want an error", err)
+			}
+			verr, ok := err.(*IonValidationError)
+			if !ok || verr.Level < tt.level || !strings.Contains(verr.Reason, tt.reason) {
+				t.Errorf("got err = %v, want = %q on level %v or above", err, tt.reason, tt.level)
+			}
+		})
+	}
+}